@@ -0,0 +1,106 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+// This is the "race" version of OnEdge's allowlisting support.  Compare this version to
+// "allow_norace.go", which does essentially nothing.
+
+// Some global state changes made before a panic are intentional, e.g., incrementing a metrics counter.
+// Allow and AllowVar let such changes be documented in code, rather than in a TSan suppression.
+//   Allow runs a block of code under a function (allowed) whose reports are suppressed in init below.
+// Within a shadow thread, allowed also disables the race detector's handling of synchronization events.
+// Were the shadow thread to, say, lock a mutex within the block that the main thread had also locked,
+// the race detector would think that the two threads were synchronized, and global state changes made
+// after the block would not be reported.
+//   AllowVar works differently.  ThreadSanitizer does not support suppressing reports by address.  So
+// immediately before a shadow thread calls its function, and again immediately after, OnEdge resets the
+// race detector's record of the accesses to each allowed variable (using __tsan_malloc, which is what
+// the Go runtime calls when it allocates memory).  Thus, accesses made by the main thread and the
+// shadow thread are never compared.
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+)
+
+/*
+#include <stdint.h>
+struct SuppressionContext;
+struct SuppressionContext *__tsan_Suppressions();
+int __sanitizer_SuppressionContext_Parse(struct SuppressionContext *this, const char *value);
+void __tsan_malloc(void *thr, void *pc, uintptr_t p, uintptr_t sz);
+*/
+import "C"
+
+func init() {
+	C.__sanitizer_SuppressionContext_Parse(
+		C.__tsan_Suppressions(),
+		C.CString("race:^github.com/trailofbits/on-edge.allowed$"),
+	)
+}
+
+//====================================================================================================//
+
+// allowedVars contains the pointers passed to AllowVar.
+var allowedVars []interface{}
+
+//====================================================================================================//
+
+// Allow calls f.  Data races involving memory accesses made by f (or by functions that f calls) are not
+// reported.
+func Allow(f func()) {
+	allowed(f)
+}
+
+// allowed is called by Allow.  It is a separate function so that reports associated with it can be
+// suppressed, and so it must not be inlined.
+//
+//go:noinline
+func allowed(f func()) {
+	if inShadowThread() {
+		runtime.RaceDisable()
+		defer runtime.RaceEnable()
+	}
+	f()
+}
+
+// AllowVar causes data races between the main thread and a shadow thread that involve the variable
+// pointed to by ptr to not be reported.
+func AllowVar(ptr interface{}) {
+	if v := reflect.ValueOf(ptr); v.Kind() != reflect.Ptr || v.IsNil() {
+		panic(fmt.Sprintf("onedge.AllowVar: expected a non-nil pointer, got %T", ptr))
+	}
+	allowedVars = append(allowedVars, ptr)
+}
+
+//====================================================================================================//
+
+// resetAllowedVars causes the race detector to forget all prior accesses to the variables passed to
+// AllowVar.
+func resetAllowedVars() {
+	for _, ptr := range allowedVars {
+		v := reflect.ValueOf(ptr)
+		C.__tsan_malloc(nil, nil, C.uintptr_t(v.Pointer()), C.uintptr_t(v.Type().Elem().Size()))
+	}
+}
+
+//====================================================================================================//
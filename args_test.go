@@ -0,0 +1,104 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"testing"
+)
+
+//====================================================================================================//
+
+type exampleAccountT struct {
+	owner   string
+	balance int
+}
+
+//====================================================================================================//
+
+func TestArgsWithdrawPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+	checkOutput(t, output,
+		"Argument changed between WrapFunc entry and recover: args[0].balance: 10 -> 5", true)
+	checkOutput(t, output, "args[0].owner", false)
+}
+
+func ExampleArgsWithdrawPanicRecover() {
+	account := &exampleAccountT{owner: "alice", balance: 10}
+	WrapFuncArgs(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		account.balance -= 5
+		panic(fmt.Errorf(""))
+	}, account)
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestArgsAppendSortPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+	checkOutput(t, output, "recover: args[0][0]: 2 -> 1", true)
+	checkOutput(t, output, "recover: args[1].len: 0 -> 1", true)
+}
+
+func ExampleArgsAppendSortPanicRecover() {
+	xs := []int{2, 1}
+	m := map[string]int{}
+	WrapFuncArgs(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		xs[0], xs[1] = xs[1], xs[0]
+		m["x"] = 1
+		panic(fmt.Errorf(""))
+	}, xs, m)
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestArgsReadPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "Argument changed", false)
+}
+
+func ExampleArgsReadPanicRecover() {
+	account := &exampleAccountT{owner: "alice", balance: 10}
+	WrapFuncArgs(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		if account.balance < 20 {
+			panic(fmt.Errorf("insufficient funds"))
+		}
+	}, account)
+	// Output:
+}
+
+//====================================================================================================//
@@ -0,0 +1,123 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// This file contains OnEdge's support for channels.  Sending on a buffered channel, or closing a
+// channel, is a global state change.  But Go's race detector does not consider such an operation to
+// be racy, because channel operations synchronize.  So a Chan records its sends and closes using
+// RecordEffect.
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"sync"
+)
+
+//====================================================================================================//
+
+// chanEffectKind is the kind passed to RecordEffect.
+const chanEffectKind = "chan"
+
+//====================================================================================================//
+
+// Chan wraps a channel so that values sent on it, and its closing, are reported when made between
+// entry to a function wrapped by WrapFunc and a panic.  Values can be received from C directly, or with
+// Recv.
+//
+// Within a shadow thread, sends and closes are not performed.  Thus, values are not sent twice, and a
+// channel is not closed twice.  Instead, the values sent are queued, and are received by later calls to
+// Recv made during the same re-execution of the wrapped function.  Within a shadow thread, Recv never
+// receives from the wrapped channel, as doing so could block forever or take a value meant for another
+// receiver.  So a wrapped function that receives from a Chan should use Recv, and should expect it to
+// fail within a shadow thread for values that the function did not send itself.
+type Chan[T any] struct {
+	C    chan T
+	Name string
+
+	// shadowMutex protects the remaining fields, which are used only by shadow threads.  shadowSends
+	// are the values sent, and not yet received, during the re-execution identified by shadowRun (see
+	// ShadowRun).
+	shadowMutex sync.Mutex
+	shadowRun   uint64
+	shadowSends []T
+}
+
+// NewChan returns a Chan wrapping a new channel with the given buffer size.  name is used to identify
+// the channel in reports.
+func NewChan[T any](name string, size int) *Chan[T] {
+	return WrapChan(name, make(chan T, size))
+}
+
+// WrapChan returns a Chan wrapping c.  name is used to identify the channel in reports.
+func WrapChan[T any](name string, c chan T) *Chan[T] {
+	return &Chan[T]{C: c, Name: name}
+}
+
+//====================================================================================================//
+
+// Send sends v on the wrapped channel.
+func (c *Chan[T]) Send(v T) {
+	RecordEffect(chanEffectKind, fmt.Sprintf("send %v on %s", v, c.Name))
+	if InShadowThread() {
+		c.lockShadow()
+		c.shadowSends = append(c.shadowSends, v)
+		c.shadowMutex.Unlock()
+		return
+	}
+	c.C <- v
+}
+
+// Close closes the wrapped channel.
+func (c *Chan[T]) Close() {
+	RecordEffect(chanEffectKind, fmt.Sprintf("close %s", c.Name))
+	if InShadowThread() {
+		return
+	}
+	close(c.C)
+}
+
+// Recv receives a value from the wrapped channel.  Like v, ok := <-c.C, Recv returns false iff the
+// channel is closed and empty.  Within a shadow thread, Recv receives the values queued by Send, and
+// returns false once there are none.
+func (c *Chan[T]) Recv() (T, bool) {
+	if InShadowThread() {
+		c.lockShadow()
+		defer c.shadowMutex.Unlock()
+		if len(c.shadowSends) <= 0 {
+			var zero T
+			return zero, false
+		}
+		v := c.shadowSends[0]
+		c.shadowSends = c.shadowSends[1:]
+		return v, true
+	}
+	v, ok := <-c.C
+	return v, ok
+}
+
+// lockShadow acquires shadowMutex, and discards the sends made during an earlier re-execution of a
+// wrapped function.
+func (c *Chan[T]) lockShadow() {
+	c.shadowMutex.Lock()
+	if run := ShadowRun(); run != c.shadowRun {
+		c.shadowRun = run
+		c.shadowSends = nil
+	}
+}
+
+//====================================================================================================//
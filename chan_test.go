@@ -0,0 +1,142 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"testing"
+)
+
+//====================================================================================================//
+
+func TestChanSendPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "recover (chan): send 1 on c", true)
+}
+
+func ExampleChanSendPanicRecover() {
+	c := NewChan[int]("c", 2)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		c.Send(1)
+		panic(fmt.Errorf(""))
+	})
+	fmt.Println(len(c.C))
+	// Output: 1
+}
+
+//====================================================================================================//
+
+func TestChanClosePanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "recover (chan): close c", true)
+	checkOutput(t, output, "close of closed channel", false)
+}
+
+func ExampleChanClosePanicRecover() {
+	c := NewChan[int]("c", 0)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		c.Close()
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestChanSendRecvPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "recover (chan): send 1 on c", true)
+	checkOutput(t, output, "did not panic", false)
+}
+
+// The shadow thread's Recv must receive the value queued by its Send, rather than block.
+func ExampleChanSendRecvPanicRecover() {
+	c := NewChan[int]("c", 1)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		c.Send(1)
+		if v, ok := c.Recv(); !ok || v != 1 {
+			return
+		}
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestChanRecvUnsentPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+}
+
+// The shadow thread's Recv must not receive the value that the main thread left in the channel.
+func ExampleChanRecvUnsentPanicRecover() {
+	c := NewChan[int]("c", 2)
+	c.Send(1)
+	c.Send(2)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		c.Recv()
+		panic(fmt.Errorf(""))
+	})
+	fmt.Println(len(c.C))
+	// Output: 1
+}
+
+//====================================================================================================//
+
+func TestChanSendNoPanic(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "External state change", false)
+}
+
+func ExampleChanSendNoPanic() {
+	c := NewChan[int]("c", 1)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		c.Send(1)
+	})
+	// Output:
+}
+
+//====================================================================================================//
@@ -0,0 +1,112 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+// This is the "race" version of OnEdge's checkpoint support.  Compare this version to
+// "checkpoint_norace.go", in which Checkpoint does nothing.
+
+// Many functions have a "commit point" after which their global state changes are meant to stick, even
+// if a later step panics.  A call to Checkpoint marks such a point.  The main thread counts the calls
+// to Checkpoint made directly by the function passed to the enclosing most WrapFuncR, and passes that
+// count to the shadow thread.  When the shadow thread makes the same number of calls, it panics with a
+// checkpointReachedT.  The shadow thread's WrapRecover forwards that panic to the main thread (which
+// treats it as matching the main thread's panic), and then re-panics so that the remainder of the
+// wrapped function's deferred recover is not executed.  Thus, the shadow thread re-executes the wrapped
+// function only up to the last checkpoint that the main thread reached, and global state changes made
+// after that checkpoint are not detected as data races.  External effects (see "effect_race.go")
+// recorded after that checkpoint are not reported either.  The other checks (invariants, Options.Args,
+// held locks, and the optional checks) are unaffected: they compare the state when WrapRecover
+// received the panic, or when WrapFuncR returns, to the state when WrapFuncR was called, and so may
+// report changes made after the checkpoint.
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"runtime"
+)
+
+//====================================================================================================//
+
+// checkpointReachedT is the value with which a shadow thread panics upon reaching the last checkpoint
+// that the main thread reached.
+type checkpointReachedT struct {
+	site Site
+}
+
+func (checkpointReached checkpointReachedT) String() string {
+	return "checkpoint at " + checkpointReached.site.String()
+}
+
+// shadowThreadCheckpoints is the number of calls to Checkpoint that the currently running shadow thread
+// has made.  shadowThreadCheckpointTarget is the number of calls that the main thread made.
+var (
+	shadowThreadCheckpoints      = 0
+	shadowThreadCheckpointTarget = 0
+)
+
+//====================================================================================================//
+
+// Checkpoint marks a commit point within a function wrapped by WrapFunc or WrapFuncR.  If the function
+// panics after calling Checkpoint, then the shadow thread re-executes the function only up to the call
+// to Checkpoint.  So global state changes made after the call are not detected as data races, and
+// external effects recorded after the call are not reported.  The other checks are unaffected.
+func Checkpoint() {
+	if inShadowThread() {
+		if shadowThreadWrapFuncDepth > 0 {
+			return
+		}
+		shadowThreadCheckpoints++
+		if shadowThreadCheckpoints == shadowThreadCheckpointTarget {
+			panic(checkpointReachedT{site: callerSite()})
+		}
+		return
+	}
+	if len(mainThreadStack) <= 0 || !haveCallers(mainThreadStack[len(mainThreadStack)-1].callers) {
+		return
+	}
+	wrappedFunc := &mainThreadStack[len(mainThreadStack)-1]
+	wrappedFunc.checkpoints++
+	wrappedFunc.checkpointSite = callerSite()
+	wrappedFunc.effectsAtCheckpoint = len(mainThreadEffects)
+}
+
+// callerSite returns the location of the call to Checkpoint.
+func callerSite() Site {
+	_, file, line, ok := runtime.Caller(2)
+	if !ok {
+		return Site{}
+	}
+	return Site{File: file, Line: line}
+}
+
+//====================================================================================================//
+
+// reportCheckpoint reports the last checkpoint that the main thread reached, if any.
+func reportCheckpoint(wrappedFunc wrappedFuncT) {
+	if wrappedFunc.checkpoints <= 0 {
+		return
+	}
+	report(
+		FindingPanicAfterCheckpoint,
+		"Panic occurred after checkpoint at %v; the shadow thread re-executes only up to it.",
+		wrappedFunc.checkpointSite,
+	)
+}
+
+//====================================================================================================//
@@ -0,0 +1,147 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+//====================================================================================================//
+
+func TestCheckpointSetFlagCheckpointPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+	checkOutput(t, output, "Panic occurred after checkpoint at ", true)
+	checkOutput(t, output, "checkpoint_test.go:", true)
+}
+
+func ExampleCheckpointSetFlagCheckpointPanicRecover() {
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		exampleFlag = true
+		Checkpoint()
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestCheckpointCheckpointSetFlagPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "Panic occurred after checkpoint at ", true)
+}
+
+func ExampleCheckpointCheckpointSetFlagPanicRecover() {
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		Checkpoint()
+		exampleFlag = true
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestCheckpointSendCheckpointSendPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "recover (chan): send 1 on c", true)
+	checkOutput(t, output, "recover (chan): send 2 on c", false)
+}
+
+func ExampleCheckpointSendCheckpointSendPanicRecover() {
+	c := NewChan[int]("c", 2)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		c.Send(1)
+		Checkpoint()
+		c.Send(2)
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestCheckpointNoPanic(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "checkpoint", false)
+}
+
+func ExampleCheckpointNoPanic() {
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		Checkpoint()
+		exampleFlag = true
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestCheckpointReporterPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+}
+
+func ExampleCheckpointReporterPanicRecover() {
+	SetReporter(func(finding Finding) {
+		fmt.Println(finding.Kind, filepath.Base(finding.Checkpoint.File))
+	})
+	RegisterInvariant("exampleFlag is false", func() error {
+		if exampleFlag {
+			return fmt.Errorf("exampleFlag is true")
+		}
+		return nil
+	})
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		exampleFlag = true
+		Checkpoint()
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+	// panic-after-checkpoint checkpoint_test.go
+	// broken-invariant checkpoint_test.go
+}
+
+//====================================================================================================//
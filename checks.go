@@ -0,0 +1,74 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// This file contains the optional checks that OnEdge can perform in addition to its usual ones.  Apart
+// from CheckProcessState, the optional checks are disabled by default, as they can be expensive.  Like
+// the rest of OnEdge, the optional checks are performed only when Go's race detector is enabled.
+
+//====================================================================================================//
+
+package onedge
+
+//====================================================================================================//
+
+// Checks is a set of optional checks.
+type Checks uint
+
+const (
+	// CheckGoroutines reports goroutines that were started by a wrapped function, and that are still
+	// running after a panic in that function has been recovered.
+	CheckGoroutines Checks = 1 << iota
+	// CheckFileDescriptors reports file descriptors that were opened by a wrapped function, and that
+	// are still open when a panic in that function is recovered.
+	CheckFileDescriptors
+	// CheckIdempotence causes a wrapped function that returns without panicking to be re-executed in
+	// its shadow thread.  Data races between the two executions are reported, as are differences
+	// between their results.  Functions for which this check fails are unsafe to retry.
+	CheckIdempotence
+	// CheckProcessState reports changes to process state that Go's race detector does not see (e.g.,
+	// environment variables and the working directory) made between entry to a wrapped function and the
+	// recovery of a panic in that function.  It is enabled by default.
+	CheckProcessState
+)
+
+// enabledChecks contains the optional checks that are currently enabled.
+var enabledChecks = CheckProcessState
+
+//====================================================================================================//
+
+// EnableChecks enables the given optional checks.
+func EnableChecks(checks Checks) {
+	enabledChecks |= checks
+}
+
+// DisableChecks disables the given optional checks.
+func DisableChecks(checks Checks) {
+	enabledChecks &^= checks
+}
+
+//====================================================================================================//
+
+// Options are per-call options for WrapFuncOpts and WrapFuncROpts.
+type Options struct {
+	// Checks contains optional checks to perform in addition to those enabled with EnableChecks.
+	Checks Checks
+	// Args contains pointers, slices, maps, etc. shared between the caller and the wrapped function.
+	// Each is snapshotted on entry to the wrapped function, and any changes to them are reported when a
+	// panic is recovered (see WrapFuncArgs).
+	Args []interface{}
+}
+
+//====================================================================================================//
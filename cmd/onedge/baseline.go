@@ -0,0 +1,170 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// This file contains the onedge command's support for baselines.  A baseline is a file containing the
+// fingerprints of known findings, one per line.  When a baseline is given, findings whose fingerprints
+// it contains are suppressed, so that only new findings are reported.
+//   A fingerprint contains only the stable parts of a finding, so that it survives unrelated changes
+// to the code under test.  A data race's fingerprint is derived from its two accesses, much like the
+// output of scripts/normalize.py: the accesses are put in a fixed order, and addresses, goroutine ids,
+// and program counter offsets are dropped.  Moreover, line numbers are kept only for files under the
+// current directory, as the others (e.g., those of the standard library) can change with the Go
+// version.  A fingerprint also contains the site of the enclosing most call to WrapFunc, if known.
+//   An OnEdge finding's fingerprint contains its kind, its site, and its message, normalized in the
+// same way: addresses and numbers (e.g., line numbers) are replaced, the current directory is removed
+// from paths, and runs of whitespace are collapsed, so that the fingerprint fits on one line.
+
+//====================================================================================================//
+
+package main
+
+//====================================================================================================//
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	onedge "github.com/trailofbits/on-edge"
+	"github.com/trailofbits/on-edge/internal/racereport"
+)
+
+//====================================================================================================//
+
+// baselineHeader is written at the start of each baseline.
+const baselineHeader = "# OnEdge baseline: the fingerprints of known findings, one per line.\n"
+
+// hexRegexp and numberRegexp match the addresses and numbers replaced by normalizeMessage.
+var (
+	hexRegexp    = regexp.MustCompile(`0x[0-9A-Fa-f]+`)
+	numberRegexp = regexp.MustCompile(`\b[0-9]+\b`)
+)
+
+//====================================================================================================//
+
+// readBaseline returns the fingerprints contained in the baseline at path.  Blank lines, and lines
+// beginning with "#", are ignored.
+func readBaseline(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	baseline := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		baseline[line] = true
+	}
+	return baseline, scanner.Err()
+}
+
+// writeBaseline writes fingerprints, sorted, to the baseline at path.
+func writeBaseline(path string, fingerprints map[string]bool) error {
+	lines := make([]string, 0, len(fingerprints))
+	for fingerprint := range fingerprints {
+		lines = append(lines, fingerprint)
+	}
+	sort.Strings(lines)
+	var builder strings.Builder
+	builder.WriteString(baselineHeader)
+	for _, line := range lines {
+		builder.WriteString(line + "\n")
+	}
+	return os.WriteFile(path, []byte(builder.String()), 0644)
+}
+
+//====================================================================================================//
+
+// findingFingerprint returns the fingerprint of an OnEdge finding: its kind, site, and normalized
+// message.
+func findingFingerprint(finding onedge.Finding) string {
+	fingerprint := string(finding.Kind)
+	if finding.Site != (onedge.Site{}) {
+		fingerprint += " at " + relativePosition(finding.Site.File, finding.Site.Line)
+	}
+	if message := normalizeMessage(finding.Message); message != "" {
+		fingerprint += " | " + message
+	}
+	return fingerprint
+}
+
+// normalizeMessage returns message with addresses and numbers replaced by "0x?" and "?", respectively,
+// with the current directory removed from paths, and with each run of whitespace replaced by a single
+// space.
+func normalizeMessage(message string) string {
+	if dir, err := os.Getwd(); err == nil {
+		message = strings.ReplaceAll(message, dir+string(filepath.Separator), "")
+	}
+	message = hexRegexp.ReplaceAllString(message, "0x?")
+	message = numberRegexp.ReplaceAllString(message, "?")
+	return strings.Join(strings.Fields(message), " ")
+}
+
+// raceFingerprint returns the fingerprint of a data race report: the site of the enclosing most call
+// to WrapFunc, and the report's accesses.
+func raceFingerprint(report racereport.Report) string {
+	fingerprint := "data-race"
+	haveSite := false
+	accesses := make([]string, len(report.Accesses))
+	for i, access := range report.Accesses {
+		if caller, ok := access.WrapFuncCaller(); ok && !haveSite {
+			fingerprint += " at " + relativePosition(caller.File, caller.Line)
+			haveSite = true
+		}
+		accesses[i] = accessFingerprint(access)
+	}
+	// Which of the two accesses is the current one depends on scheduling.  So put them in a fixed
+	// order.
+	sort.Strings(accesses)
+	for _, access := range accesses {
+		fingerprint += " | " + access
+	}
+	return fingerprint
+}
+
+// accessFingerprint returns the fingerprint of one of a data race report's accesses.
+func accessFingerprint(access racereport.Access) string {
+	frames := make([]string, len(access.Stack))
+	for i, frame := range access.Stack {
+		frames[i] = frame.Function
+		if position := relativePosition(frame.File, frame.Line); !filepath.IsAbs(position) {
+			frames[i] += " " + position
+		}
+	}
+	return strings.ToLower(access.Op) + ": " + strings.Join(frames, ", ")
+}
+
+// relativePosition returns file:line, with file made relative to the current directory if it is under
+// the current directory.
+func relativePosition(file string, line int) string {
+	if dir, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(dir, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = filepath.ToSlash(rel)
+		}
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+//====================================================================================================//
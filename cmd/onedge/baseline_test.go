@@ -0,0 +1,172 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+package main
+
+//====================================================================================================//
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	onedge "github.com/trailofbits/on-edge"
+	"github.com/trailofbits/on-edge/internal/racereport"
+)
+
+//====================================================================================================//
+
+// raceFingerprintExpected is the fingerprint of the data race report in raceLines.
+const raceFingerprintExpected = "data-race at /p/p.go:7" +
+	" | write: p.f.func1, github.com/trailofbits/on-edge.WrapFunc.func1, " +
+	"github.com/trailofbits/on-edge.WrapFuncROpts.func2, runtime/pprof.Do, " +
+	"github.com/trailofbits/on-edge.WrapFuncROpts, github.com/trailofbits/on-edge.WrapFunc, p.f, " +
+	"p.TestB, testing.tRunner" +
+	" | write: p.f.func1, github.com/trailofbits/on-edge.WrapFunc.func1, " +
+	"github.com/trailofbits/on-edge.shadowThread"
+
+// findingFingerprintExpected is the fingerprint of the finding in findingEvent.
+const findingFingerprintExpected = "did-not-panic at /p/p.go:7" +
+	" | Shadow thread did not panic as it should have."
+
+//====================================================================================================//
+
+func TestFindingFingerprint(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	finding := onedge.Finding{
+		Kind: onedge.FindingDidNotPanic,
+		Site: onedge.Site{File: filepath.Join(dir, "p", "p.go"), Line: 7},
+	}
+	if fingerprint := findingFingerprint(finding); fingerprint != "did-not-panic at p/p.go:7" {
+		t.Fatalf("unexpected fingerprint: %s", fingerprint)
+	}
+	finding.Kind = onedge.FindingHeldLock
+	finding.Message = "Lock acquired after WrapFunc entry is still held after recover (Lock):\n" +
+		"  p.f+0x1a\n      " + filepath.Join(dir, "p", "p.go") + ":12"
+	expected := "held-lock at p/p.go:7 | Lock acquired after WrapFunc entry is still held " +
+		"after recover (Lock): p.f+0x? p/p.go:?"
+	if fingerprint := findingFingerprint(finding); fingerprint != expected {
+		t.Fatalf("unexpected fingerprint: %s", fingerprint)
+	}
+	// Findings of the same kind at the same site are distinguished by their messages.
+	other := finding
+	other.Message = "Lock acquired after WrapFunc entry is still held after recover (RLock):\n" +
+		"  p.f+0x2b\n      " + filepath.Join(dir, "p", "p.go") + ":14"
+	if findingFingerprint(other) == findingFingerprint(finding) {
+		t.Fatalf("fingerprints are equal: %s", findingFingerprint(finding))
+	}
+}
+
+func TestRaceFingerprint(t *testing.T) {
+	reports := racereport.Parse(strings.Join(raceLines, ""))
+	if len(reports) != 1 {
+		t.Fatalf("unexpected reports: %+v", reports)
+	}
+	if fingerprint := raceFingerprint(reports[0]); fingerprint != raceFingerprintExpected {
+		t.Fatalf("unexpected fingerprint: %s", fingerprint)
+	}
+	// The fingerprint should not depend on which access is the current one.
+	reports[0].Accesses[0], reports[0].Accesses[1] = reports[0].Accesses[1], reports[0].Accesses[0]
+	if fingerprint := raceFingerprint(reports[0]); fingerprint != raceFingerprintExpected {
+		t.Fatalf("unexpected fingerprint: %s", fingerprint)
+	}
+}
+
+//====================================================================================================//
+
+func TestRewriteBaselineKnown(t *testing.T) {
+	baseline := map[string]bool{findingFingerprintExpected: true, raceFingerprintExpected: true}
+	events, rewriter := rewriteEventsWithBaseline(t, baseline, baselineInput(t))
+	expected := []eventT{
+		{Action: "fail", Package: "p", Test: "TestB"},
+		{Action: "fail", Package: "p"},
+	}
+	checkEvents(t, events, expected)
+	if rewriter.newFindings != 0 || rewriter.knownFindings != 2 || !rewriter.failuresKnown() {
+		t.Fatalf("unexpected rewriter: %+v", rewriter)
+	}
+}
+
+func TestRewriteBaselineNew(t *testing.T) {
+	baseline := map[string]bool{findingFingerprintExpected: true}
+	events, rewriter := rewriteEventsWithBaseline(t, baseline, baselineInput(t))
+	if len(events) != len(raceLines)+3 {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if rewriter.newFindings != 1 || rewriter.knownFindings != 1 || rewriter.failuresKnown() {
+		t.Fatalf("unexpected rewriter: %+v", rewriter)
+	}
+	if len(rewriter.fingerprints) != 2 {
+		t.Fatalf("unexpected fingerprints: %v", rewriter.fingerprints)
+	}
+}
+
+func TestRewriteBaselineOtherFailure(t *testing.T) {
+	baseline := map[string]bool{findingFingerprintExpected: true, raceFingerprintExpected: true}
+	input := baselineInput(t) + `{"Action":"fail","Package":"p","Test":"TestC"}` + "\n"
+	_, rewriter := rewriteEventsWithBaseline(t, baseline, input)
+	if rewriter.failuresKnown() {
+		t.Fatal("failure of TestC considered known")
+	}
+}
+
+func TestReadWriteBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline")
+	fingerprints := map[string]bool{findingFingerprintExpected: true, raceFingerprintExpected: true}
+	if err := writeBaseline(path, fingerprints); err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := readBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(baseline) != 2 ||
+		!baseline[findingFingerprintExpected] ||
+		!baseline[raceFingerprintExpected] {
+		t.Fatalf("unexpected baseline: %v", baseline)
+	}
+}
+
+//====================================================================================================//
+
+// baselineInput returns an event stream containing a finding and a data race report, followed by the
+// failure of TestB and its package.
+func baselineInput(t *testing.T) string {
+	data, err := json.Marshal(onedge.Finding{
+		Kind:    onedge.FindingDidNotPanic,
+		Site:    onedge.Site{File: "/p/p.go", Line: 7},
+		Message: "Shadow thread did not panic as it should have.",
+		Test:    "TestB",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var input strings.Builder
+	input.WriteString(outputEvent(t, "TestA", onedge.JSONPrefix+string(data)+"\n"))
+	for _, line := range raceLines {
+		input.WriteString(outputEvent(t, "TestA", line))
+	}
+	input.WriteString(`{"Action":"fail","Package":"p","Test":"TestB"}` + "\n")
+	input.WriteString(`{"Action":"fail","Package":"p"}` + "\n")
+	return input.String()
+}
+
+//====================================================================================================//
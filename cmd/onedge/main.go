@@ -0,0 +1,486 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// Command onedge runs Go tests with OnEdge enabled, and attributes OnEdge's findings and Go's data race
+// reports to the tests that produced them.
+//
+// Usage:
+//
+//	onedge test [-baseline file [-update-baseline]] [-sarif file] [build/test flags] [packages]
+//		[flags for test binary]
+//
+// "onedge test" runs "go test -race -json" with the given arguments, and writes the resulting event
+// stream (see "go doc test2json") to stdout.  Within the stream, each OnEdge finding is replaced by
+// annotated output events for the test during which it was reported.  Similarly, the output events
+// making up each data race report are annotated and attributed to the test whose stack appears in the
+// report.  Tests run in parallel can thus be told apart, and CI systems can show findings inline per
+// test.
+//
+// The exit status is that of "go test", except as described below.
+//
+// The -baseline flag names a file containing the fingerprints of known findings (see "baseline.go").
+// Known findings, including data races, are removed from the stream, and a summary is printed to
+// stderr.  The exit status is then 1 if any new findings were reported.  Otherwise, if "go test"
+// failed, but each failing test had a known data race, the exit status is 0.  (A test that has a known
+// data race, and that fails for some other reason as well, is not detected.)  With -update-baseline,
+// the file is instead overwritten with the fingerprints of all findings reported, and nothing is
+// removed from the stream.
+//
+// The -sarif flag names a file to which a SARIF 2.1.0 log of the findings reported (less any known
+// findings) is written (see package onedgesarif).  The log includes, for each data race, the location
+// of the call to WrapRecover that received the panic preceding the race.
+package main
+
+//====================================================================================================//
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	onedge "github.com/trailofbits/on-edge"
+	"github.com/trailofbits/on-edge/internal/racereport"
+	"github.com/trailofbits/on-edge/onedgesarif"
+)
+
+//====================================================================================================//
+
+// eventT is an event in the stream produced by "go test -json" (see "go doc test2json").
+type eventT struct {
+	Time    *time.Time `json:",omitempty"`
+	Action  string
+	Package string   `json:",omitempty"`
+	Test    string   `json:",omitempty"`
+	Elapsed *float64 `json:",omitempty"`
+	Output  string   `json:",omitempty"`
+}
+
+// raceDelimiter is the line printed before and after each data race report.
+const raceDelimiter = "==================\n"
+
+//====================================================================================================//
+
+// optionsT are the onedge command's own options, which precede the arguments passed to "go test".
+type optionsT struct {
+	baseline       string
+	updateBaseline bool
+	sarif          string
+}
+
+// shadowT is the object following onedge.ShadowPrefix on the line printed just before a shadow
+// thread re-executes a wrapped function.
+type shadowT struct {
+	Site        onedge.Site
+	RecoverSite onedge.Site
+}
+
+//====================================================================================================//
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "test" {
+		usage()
+	}
+	opts, args := parseOptions(os.Args[2:])
+	os.Exit(runTest(opts, args))
+}
+
+// usage prints a usage message and exits.
+func usage() {
+	fmt.Fprintf(
+		os.Stderr,
+		"usage: %s test [-baseline file [-update-baseline]] [-sarif file] [build/test flags] "+
+			"[packages] [flags for test binary]\n",
+		os.Args[0],
+	)
+	os.Exit(2)
+}
+
+// parseOptions parses the onedge command's options from the start of args, and returns them along
+// with the remaining arguments.
+func parseOptions(args []string) (optionsT, []string) {
+	var opts optionsT
+	for len(args) > 0 {
+		if args[0] == "-update-baseline" {
+			opts.updateBaseline = true
+			args = args[1:]
+		} else if args[0] == "-baseline" && len(args) >= 2 {
+			opts.baseline = args[1]
+			args = args[2:]
+		} else if strings.HasPrefix(args[0], "-baseline=") {
+			opts.baseline = strings.TrimPrefix(args[0], "-baseline=")
+			args = args[1:]
+		} else if args[0] == "-sarif" && len(args) >= 2 {
+			opts.sarif = args[1]
+			args = args[2:]
+		} else if strings.HasPrefix(args[0], "-sarif=") {
+			opts.sarif = strings.TrimPrefix(args[0], "-sarif=")
+			args = args[1:]
+		} else {
+			break
+		}
+	}
+	if opts.updateBaseline && opts.baseline == "" {
+		usage()
+	}
+	return opts, args
+}
+
+// runTest runs "go test -race -json" with args, rewrites its event stream, and returns an exit status
+// (see above).
+func runTest(opts optionsT, args []string) int {
+	rewriter := newRewriter(os.Stdout)
+	if opts.baseline != "" && !opts.updateBaseline {
+		baseline, err := readBaseline(opts.baseline)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "onedge: %v\n", err)
+			return 1
+		}
+		rewriter.baseline = baseline
+	}
+	if opts.sarif != "" {
+		rewriter.sarif = onedgesarif.NewLog()
+	}
+	code := goTest(rewriter, args)
+	if opts.sarif != "" {
+		if err := writeSARIF(opts.sarif, rewriter.sarif); err != nil {
+			fmt.Fprintf(os.Stderr, "onedge: %v\n", err)
+			return 1
+		}
+	}
+	if opts.updateBaseline {
+		if err := writeBaseline(opts.baseline, rewriter.fingerprints); err != nil {
+			fmt.Fprintf(os.Stderr, "onedge: %v\n", err)
+			return 1
+		}
+		return code
+	}
+	if rewriter.baseline == nil {
+		return code
+	}
+	fmt.Fprintf(
+		os.Stderr,
+		"onedge: %d new finding(s), %d known finding(s) suppressed\n",
+		rewriter.newFindings,
+		rewriter.knownFindings,
+	)
+	if rewriter.newFindings > 0 {
+		return 1
+	}
+	if code != 0 && rewriter.failuresKnown() {
+		return 0
+	}
+	return code
+}
+
+// goTest runs "go test -race -json" with args, rewrites its event stream with rewriter, and returns
+// its exit status.
+func goTest(rewriter *rewriterT, args []string) int {
+	cmd := exec.Command("go", append([]string{"test", "-race", "-json"}, args...)...)
+	cmd.Env = append(os.Environ(), onedge.FormatEnv+"=json")
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "onedge: %v\n", err)
+		return 1
+	}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "onedge: %v\n", err)
+		return 1
+	}
+	if err := rewriter.rewrite(stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "onedge: %v\n", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "onedge: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// writeSARIF writes log to the file at path.
+func writeSARIF(path string, log *onedgesarif.Log) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := log.Write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+//====================================================================================================//
+
+// rewriterT rewrites an event stream, attributing findings to tests.
+type rewriterT struct {
+	encoder *json.Encoder
+	// races contains, for each package, the output events of the data race report currently being
+	// printed, if any.
+	races map[string][]eventT
+	// baseline contains the fingerprints of known findings, which are removed from the stream.  If
+	// baseline is nil, no findings are removed.
+	baseline map[string]bool
+	// fingerprints contains the fingerprints of all findings seen.  newFindings and knownFindings are
+	// the numbers of findings seen that are not, and are, in baseline.
+	fingerprints  map[string]bool
+	newFindings   int
+	knownFindings int
+	// failedTests contains the tests that failed, and knownRaceTests contains the tests to which known
+	// data races were attributed.  Both are keyed by package and top-level test name.  failedPackages
+	// contains the packages that failed.
+	failedTests    map[string]bool
+	knownRaceTests map[string]bool
+	failedPackages map[string]bool
+	// shadows contains, for each package, the most recent shadowT printed.  The lines containing them
+	// are removed from the stream.
+	shadows map[string]shadowT
+	// sarif, if non-nil, is the SARIF log to which findings that are not known are added.
+	sarif *onedgesarif.Log
+}
+
+// newRewriter returns a rewriterT that writes the rewritten event stream to w.
+func newRewriter(w io.Writer) *rewriterT {
+	return &rewriterT{
+		encoder:        json.NewEncoder(w),
+		races:          make(map[string][]eventT),
+		fingerprints:   make(map[string]bool),
+		failedTests:    make(map[string]bool),
+		knownRaceTests: make(map[string]bool),
+		failedPackages: make(map[string]bool),
+		shadows:        make(map[string]shadowT),
+	}
+}
+
+// known records a finding with the given fingerprint, and returns true iff the finding is known.
+func (rewriter *rewriterT) known(fingerprint string) bool {
+	rewriter.fingerprints[fingerprint] = true
+	if rewriter.baseline[fingerprint] {
+		rewriter.knownFindings++
+		return true
+	}
+	rewriter.newFindings++
+	return false
+}
+
+// failuresKnown returns true iff each package that failed had a failing test, and each failing test
+// had a known data race.
+func (rewriter *rewriterT) failuresKnown() bool {
+	for pkg := range rewriter.failedPackages {
+		failed := false
+		for key := range rewriter.failedTests {
+			if strings.HasPrefix(key, pkg+" ") {
+				failed = true
+			}
+		}
+		if !failed {
+			return false
+		}
+	}
+	for key := range rewriter.failedTests {
+		if !rewriter.knownRaceTests[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// testKey returns the key for pkg's test, in the form used by failedTests and knownRaceTests.
+func testKey(pkg string, test string) string {
+	return pkg + " " + strings.SplitN(test, "/", 2)[0]
+}
+
+// rewrite reads an event stream from r and writes the rewritten stream.  Lines that are not events
+// (e.g., the output of a build failure) are passed through as output events.
+func (rewriter *rewriterT) rewrite(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		var event eventT
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			event = eventT{Action: "output", Output: scanner.Text() + "\n"}
+		}
+		if err := rewriter.handle(event); err != nil {
+			return err
+		}
+	}
+	for pkg := range rewriter.races {
+		if err := rewriter.flushRace(pkg); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// handle rewrites a single event.
+func (rewriter *rewriterT) handle(event eventT) error {
+	if event.Action != "output" {
+		if err := rewriter.flushRace(event.Package); err != nil {
+			return err
+		}
+		if event.Action == "fail" && event.Test != "" {
+			rewriter.failedTests[testKey(event.Package, event.Test)] = true
+		} else if event.Action == "fail" {
+			rewriter.failedPackages[event.Package] = true
+		}
+		return rewriter.encoder.Encode(event)
+	}
+	if strings.HasPrefix(event.Output, onedge.ShadowPrefix) {
+		var shadow shadowT
+		data := strings.TrimPrefix(event.Output, onedge.ShadowPrefix)
+		if err := json.Unmarshal([]byte(data), &shadow); err == nil {
+			rewriter.shadows[event.Package] = shadow
+			return nil
+		}
+	}
+	if race, ok := rewriter.races[event.Package]; ok {
+		rewriter.races[event.Package] = append(race, event)
+		if event.Output == raceDelimiter {
+			return rewriter.flushRace(event.Package)
+		}
+		return nil
+	}
+	if event.Output == raceDelimiter {
+		rewriter.races[event.Package] = []eventT{event}
+		return nil
+	}
+	if strings.HasPrefix(event.Output, onedge.JSONPrefix) {
+		var finding onedge.Finding
+		data := strings.TrimPrefix(event.Output, onedge.JSONPrefix)
+		if err := json.Unmarshal([]byte(data), &finding); err == nil {
+			return rewriter.emitFinding(event, finding)
+		}
+	}
+	return rewriter.encoder.Encode(event)
+}
+
+// emitFinding writes the annotated output events for finding, which was reported in event, unless
+// finding is known.
+func (rewriter *rewriterT) emitFinding(event eventT, finding onedge.Finding) error {
+	if rewriter.known(findingFingerprint(finding)) {
+		return nil
+	}
+	if rewriter.sarif != nil {
+		rewriter.sarif.Report(finding)
+	}
+	if finding.Test != "" {
+		event.Test = finding.Test
+	}
+	header := fmt.Sprintf("onedge: %s", finding.Kind)
+	if finding.Site != (onedge.Site{}) {
+		header += fmt.Sprintf(" at %v", finding.Site)
+	}
+	return rewriter.emitLines(event, header+"\n"+prefixLines("    ", finding.Message))
+}
+
+// flushRace writes the buffered output events of pkg's current data race report, if any.  If the
+// report is complete, the events are attributed to the test whose stack appears in the report, and are
+// preceded by an annotation.  If the report is complete and known, nothing is written.
+func (rewriter *rewriterT) flushRace(pkg string) error {
+	race, ok := rewriter.races[pkg]
+	if !ok {
+		return nil
+	}
+	delete(rewriter.races, pkg)
+	var text strings.Builder
+	for _, event := range race {
+		text.WriteString(event.Output)
+	}
+	if reports := racereport.Parse(text.String()); len(reports) == 1 {
+		test := reports[0].Test()
+		if rewriter.known(raceFingerprint(reports[0])) {
+			if test != "" {
+				rewriter.knownRaceTests[testKey(pkg, test)] = true
+			}
+			return nil
+		}
+		if rewriter.sarif != nil {
+			rewriter.sarif.AddRace(text.String(), rewriter.recoverSite(pkg, reports[0]))
+		}
+		header := race[0]
+		if test != "" {
+			header.Test = test
+			for i := range race {
+				race[i].Test = test
+			}
+		}
+		annotation := "onedge: data-race"
+		if accesses := reports[0].Accesses; len(accesses) > 0 && len(accesses[0].Stack) > 0 {
+			annotation += fmt.Sprintf(" at %s:%d", accesses[0].Stack[0].File, accesses[0].Stack[0].Line)
+		}
+		if err := rewriter.emitLines(header, annotation+"\n"); err != nil {
+			return err
+		}
+	}
+	for _, event := range race {
+		if err := rewriter.encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recoverSite returns the location of the call to WrapRecover that received the panic preceding report,
+// if known.  That is the case if the most recent shadowT printed by pkg names the call to WrapFunc on
+// report's main thread's stack.
+func (rewriter *rewriterT) recoverSite(pkg string, report racereport.Report) onedge.Site {
+	shadow, ok := rewriter.shadows[pkg]
+	if !ok {
+		return onedge.Site{}
+	}
+	for _, access := range report.Accesses {
+		if caller, ok := access.WrapFuncCaller(); ok {
+			if caller.File == shadow.Site.File && caller.Line == shadow.Site.Line {
+				return shadow.RecoverSite
+			}
+			break
+		}
+	}
+	return onedge.Site{}
+}
+
+// emitLines writes an output event, based on event, for each line of s.
+func (rewriter *rewriterT) emitLines(event eventT, s string) error {
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if line == "" {
+			continue
+		}
+		event.Output = line
+		if err := rewriter.encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefixLines prefixes each line of s with prefix, and ensures that s ends with a newline.
+func prefixLines(prefix string, s string) string {
+	var builder strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		builder.WriteString(prefix + line + "\n")
+	}
+	return builder.String()
+}
+
+//====================================================================================================//
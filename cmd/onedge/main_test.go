@@ -0,0 +1,228 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+package main
+
+//====================================================================================================//
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	onedge "github.com/trailofbits/on-edge"
+	"github.com/trailofbits/on-edge/onedgesarif"
+)
+
+//====================================================================================================//
+
+// outputEvent returns the JSON encoding of an output event.
+func outputEvent(t *testing.T, test string, output string) string {
+	data, err := json.Marshal(eventT{Action: "output", Package: "p", Test: test, Output: output})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data) + "\n"
+}
+
+// rewriteEvents rewrites input and returns the resulting events.
+func rewriteEvents(t *testing.T, input string) []eventT {
+	events, _ := rewriteEventsWithBaseline(t, nil, input)
+	return events
+}
+
+// rewriteEventsWithBaseline rewrites input using baseline, and returns the resulting events and the
+// rewriter.
+func rewriteEventsWithBaseline(
+	t *testing.T,
+	baseline map[string]bool,
+	input string,
+) ([]eventT, *rewriterT) {
+	var buffer bytes.Buffer
+	rewriter := newRewriter(&buffer)
+	rewriter.baseline = baseline
+	if err := rewriter.rewrite(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	var events []eventT
+	decoder := json.NewDecoder(&buffer)
+	for decoder.More() {
+		var event eventT
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	return events, rewriter
+}
+
+//====================================================================================================//
+
+func TestRewriteFinding(t *testing.T) {
+	data, err := json.Marshal(onedge.Finding{
+		Kind:    onedge.FindingDidNotPanic,
+		Site:    onedge.Site{File: "/p/p.go", Line: 7},
+		Message: "Shadow thread did not panic as it should have.",
+		Test:    "TestB",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := outputEvent(t, "TestA", onedge.JSONPrefix+string(data)+"\n") +
+		outputEvent(t, "TestA", "ok\n")
+	events := rewriteEvents(t, input)
+	expected := []eventT{
+		{Action: "output", Package: "p", Test: "TestB", Output: "onedge: did-not-panic at /p/p.go:7\n"},
+		{
+			Action:  "output",
+			Package: "p",
+			Test:    "TestB",
+			Output:  "    Shadow thread did not panic as it should have.\n",
+		},
+		{Action: "output", Package: "p", Test: "TestA", Output: "ok\n"},
+	}
+	checkEvents(t, events, expected)
+}
+
+// raceLines is the output of a data race report whose main thread access is made by TestB.
+var raceLines = []string{
+	raceDelimiter,
+	"WARNING: DATA RACE\n",
+	"Write at 0x000001b287ad by goroutine 9:\n",
+	"  p.f.func1()\n",
+	"      /p/p.go:8 +0x3d\n",
+	"  github.com/trailofbits/on-edge.WrapFunc.func1()\n",
+	"      /on-edge/onedge_race.go:183 +0x2e\n",
+	"  github.com/trailofbits/on-edge.shadowThread()\n",
+	"      /on-edge/onedge_race.go:513 +0x1e8\n",
+	"\n",
+	"Previous write at 0x000001b287ad by main goroutine:\n",
+	"  p.f.func1()\n",
+	"      /p/p.go:8 +0x3d\n",
+	"  github.com/trailofbits/on-edge.WrapFunc.func1()\n",
+	"      /on-edge/onedge_race.go:183 +0x2e\n",
+	"  github.com/trailofbits/on-edge.WrapFuncROpts.func2()\n",
+	"      /on-edge/onedge_race.go:279 +0xc2\n",
+	"  runtime/pprof.Do()\n",
+	"      /go/src/runtime/pprof/runtime.go:57 +0x111\n",
+	"  github.com/trailofbits/on-edge.WrapFuncROpts()\n",
+	"      /on-edge/onedge_race.go:277 +0xa84\n",
+	"  github.com/trailofbits/on-edge.WrapFunc()\n",
+	"      /on-edge/onedge_race.go:182 +0x84\n",
+	"  p.f()\n",
+	"      /p/p.go:7 +0x1c\n",
+	"  p.TestB()\n",
+	"      /p/p_test.go:10 +0x1c\n",
+	"  testing.tRunner()\n",
+	"      /go/src/testing/testing.go:1792 +0x225\n",
+	raceDelimiter,
+}
+
+func TestRewriteRace(t *testing.T) {
+	var input strings.Builder
+	var expected []eventT
+	expected = append(expected, eventT{
+		Action:  "output",
+		Package: "p",
+		Test:    "TestB",
+		Output:  "onedge: data-race at /p/p.go:8\n",
+	})
+	for _, line := range raceLines {
+		input.WriteString(outputEvent(t, "TestA", line))
+		expected = append(expected, eventT{Action: "output", Package: "p", Test: "TestB", Output: line})
+	}
+	input.WriteString(`{"Action":"pass","Package":"p","Test":"TestA"}` + "\n")
+	expected = append(expected, eventT{Action: "pass", Package: "p", Test: "TestA"})
+	checkEvents(t, rewriteEvents(t, input.String()), expected)
+}
+
+func TestRewriteSARIF(t *testing.T) {
+	data, err := json.Marshal(shadowT{
+		Site:        onedge.Site{File: "/p/p.go", Line: 7},
+		RecoverSite: onedge.Site{File: "/p/p.go", Line: 9},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := outputEvent(t, "TestA", onedge.ShadowPrefix+string(data)+"\n")
+	for _, line := range raceLines {
+		input += outputEvent(t, "TestA", line)
+	}
+	var buffer bytes.Buffer
+	rewriter := newRewriter(&buffer)
+	rewriter.sarif = onedgesarif.NewLog()
+	if err := rewriter.rewrite(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buffer.String(), "shadow: ") {
+		t.Fatalf("unexpected output: %s", buffer.String())
+	}
+	buffer.Reset()
+	if err := rewriter.sarif.Write(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Runs []struct {
+			Results []struct {
+				RuleID           string
+				RelatedLocations []struct {
+					PhysicalLocation struct {
+						Region struct {
+							StartLine int
+						}
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(buffer.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Fatalf("unexpected log: %s", buffer.String())
+	}
+	result := log.Runs[0].Results[0]
+	if result.RuleID != "data-race" ||
+		len(result.RelatedLocations) != 2 ||
+		result.RelatedLocations[0].PhysicalLocation.Region.StartLine != 7 ||
+		result.RelatedLocations[1].PhysicalLocation.Region.StartLine != 9 {
+		t.Fatalf("unexpected result: %s", buffer.String())
+	}
+}
+
+func TestRewritePassThrough(t *testing.T) {
+	input := outputEvent(t, "", "# p\n") + "not an event\n"
+	expected := []eventT{
+		{Action: "output", Package: "p", Output: "# p\n"},
+		{Action: "output", Output: "not an event\n"},
+	}
+	checkEvents(t, rewriteEvents(t, input), expected)
+}
+
+// checkEvents checks that events and expected are equal.
+func checkEvents(t *testing.T, events []eventT, expected []eventT) {
+	if len(events) != len(expected) {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	for i := range events {
+		if events[i] != expected[i] {
+			t.Fatalf("unexpected event %d: %+v != %+v", i, events[i], expected[i])
+		}
+	}
+}
+
+//====================================================================================================//
@@ -0,0 +1,56 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// This file contains the comparator that OnEdge uses to decide whether values obtained by the main
+// thread and a shadow thread are equivalent, e.g., the arguments of their panics, or their wrapped
+// functions' results.  Like the rest of OnEdge, the comparator is used only when Go's race detector is
+// enabled.
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+)
+
+//====================================================================================================//
+
+// Comparator returns true iff x (obtained by the main thread) and y (obtained by a shadow thread) are
+// equivalent.
+type Comparator func(x, y interface{}) bool
+
+// comparator is the Comparator set by SetComparator.
+var comparator Comparator = compareStrings
+
+//====================================================================================================//
+
+// SetComparator sets the Comparator used to compare panic arguments and wrapped functions' results.
+// Passing nil restores the default, which compares the values' string representations (as produced by
+// fmt's %v verb).
+func SetComparator(c Comparator) {
+	if c == nil {
+		c = compareStrings
+	}
+	comparator = c
+}
+
+// compareStrings is the default Comparator.
+func compareStrings(x, y interface{}) bool {
+	return fmt.Sprintf("%v", x) == fmt.Sprintf("%v", y)
+}
+
+//====================================================================================================//
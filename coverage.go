@@ -0,0 +1,113 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// This file contains OnEdge's recover-path coverage reports.  OnEdge can find bugs only on paths on
+// which a panic actually occurs.  So, for each site at which WrapFunc (or one of its variants) was
+// called, the reports show whether the wrapped function was entered, whether its recover received a
+// panic, and whether the shadow thread reproduced that panic.  The reports are derived from the
+// statistics returned by Stats (see "stats.go"), and thus are empty unless Go's race detector is
+// enabled.
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+//====================================================================================================//
+
+// WriteCoverage writes a table to w with one row for each site at which a call has been made.  The
+// table gives the number of calls made at the site, the number of panics received by WrapRecover, the
+// number of those panics that the shadow thread reproduced, and a status: "reproduced" if at least
+// one panic was reproduced, "not reproduced" if panics were received but none was reproduced, and "no
+// panic" if no panic was received.
+func WriteCoverage(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "SITE\tCALLS\tPANICS\tREPRODUCED\tSTATUS\n")
+	stats := Stats()
+	for _, site := range sortedSites(stats) {
+		s := stats[site]
+		fmt.Fprintf(
+			tw,
+			"%s\t%d\t%d\t%d\t%s\n",
+			site,
+			s.Calls,
+			s.Panics,
+			s.Reproduced,
+			coverageStatus(s),
+		)
+	}
+	return tw.Flush()
+}
+
+// WriteCoverProfile writes a cover profile to w, in the format written by "go test -coverprofile", so
+// that it can be viewed with "go tool cover".  The profile contains a block for each site at which a
+// call has been made, spanning the site's line.  A block's count is the number of panics that the
+// shadow thread reproduced.  Thus, a site is shown as covered iff its recover path was exercised.
+func WriteCoverProfile(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "mode: count\n"); err != nil {
+		return err
+	}
+	stats := Stats()
+	for _, site := range sortedSites(stats) {
+		s := stats[site]
+		if _, err := fmt.Fprintf(
+			w,
+			"%s:%d.1,%d.1 1 %d\n",
+			site.File,
+			site.Line,
+			site.Line+1,
+			s.Reproduced,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//====================================================================================================//
+
+// sortedSites returns the sites in stats, ordered by file and line.
+func sortedSites(stats map[Site]SiteStats) []Site {
+	sites := make([]Site, 0, len(stats))
+	for site := range stats {
+		sites = append(sites, site)
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		if sites[i].File != sites[j].File {
+			return sites[i].File < sites[j].File
+		}
+		return sites[i].Line < sites[j].Line
+	})
+	return sites
+}
+
+// coverageStatus returns the status of a site with the given statistics.
+func coverageStatus(s SiteStats) string {
+	if s.Reproduced > 0 {
+		return "reproduced"
+	} else if s.Panics > 0 {
+		return "not reproduced"
+	}
+	return "no panic"
+}
+
+//====================================================================================================//
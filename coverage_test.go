@@ -0,0 +1,138 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//====================================================================================================//
+
+// coverageWorkload calls WrapFunc at three sites: one at which a panic is reproduced, one at which no
+// panic occurs, and one at which the shadow thread panics with a different argument.
+func coverageWorkload() {
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		panic(fmt.Errorf(""))
+	})
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+	})
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		panic(fmt.Errorf("%v", InShadowThread()))
+	})
+}
+
+// printCoverage prints the coverage table with each site's file name in place of the site.
+func printCoverage() {
+	var builder strings.Builder
+	if err := WriteCoverage(&builder); err != nil {
+		panic(err)
+	}
+	for _, line := range strings.Split(strings.TrimRight(builder.String(), "\n"), "\n") {
+		fields := strings.Fields(line)
+		if fields[0] != "SITE" {
+			fields[0] = filepath.Base(fields[0][:strings.LastIndex(fields[0], ":")])
+		}
+		fmt.Println(strings.Join(fields, " "))
+	}
+}
+
+// printCoverProfile prints the cover profile with each block's file name in place of the block's
+// position, after checking that the block spans one line.
+func printCoverProfile() {
+	var builder strings.Builder
+	if err := WriteCoverProfile(&builder); err != nil {
+		panic(err)
+	}
+	lines := strings.Split(strings.TrimRight(builder.String(), "\n"), "\n")
+	fmt.Println(lines[0])
+	for _, line := range lines[1:] {
+		i := strings.LastIndex(line, ":")
+		var startLine, startCol, endLine, endCol, statements, count int
+		if _, err := fmt.Sscanf(
+			line[i+1:],
+			"%d.%d,%d.%d %d %d",
+			&startLine,
+			&startCol,
+			&endLine,
+			&endCol,
+			&statements,
+			&count,
+		); err != nil {
+			panic(err)
+		}
+		if startCol != 1 || endLine != startLine+1 || endCol != 1 || statements != 1 {
+			panic(fmt.Sprintf("unexpected block: %s", line))
+		}
+		fmt.Println(filepath.Base(line[:i]), count)
+	}
+}
+
+//====================================================================================================//
+
+func TestCoverage(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<panickedWithDifferentArgument, nil)
+}
+
+func ExampleCoverage() {
+	coverageWorkload()
+	coverageWorkload()
+	printCoverage()
+	// Output:
+	// SITE CALLS PANICS REPRODUCED STATUS
+	// coverage_test.go 2 2 2 reproduced
+	// coverage_test.go 2 0 0 no panic
+	// coverage_test.go 2 2 0 not reproduced
+}
+
+//====================================================================================================//
+
+func TestCoverageProfile(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<panickedWithDifferentArgument, nil)
+}
+
+func ExampleCoverageProfile() {
+	coverageWorkload()
+	printCoverProfile()
+	// Output:
+	// mode: count
+	// coverage_test.go 1
+	// coverage_test.go 0
+	// coverage_test.go 0
+}
+
+//====================================================================================================//
@@ -0,0 +1,94 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+// This file contains OnEdge's support for external effects, i.e., state changes made outside of the
+// program, such as writes to a database.  Go's race detector cannot see such changes.  Instead, code
+// that makes them (e.g., the onedgesql package) calls RecordEffect.  When WrapRecover receives a panic,
+// each effect recorded by the main thread since the enclosing most WrapFuncR was called is reported.
+//   Code that makes external effects should also call InShadowThread, and avoid making the effects
+// when it returns true.  Otherwise, the effects will happen twice (see "Incorporating OnEdge into your
+// project" in the README).
+
+//====================================================================================================//
+
+package onedge
+
+//====================================================================================================//
+
+// effectT is an external effect recorded by RecordEffect.
+type effectT struct {
+	kind        string
+	description string
+}
+
+// mainThreadEffects contains the effects recorded by the main thread while mainThreadStack is
+// non-empty.  Each wrappedFuncT records the length of mainThreadEffects at the time that its WrapFuncR
+// was called.
+var mainThreadEffects []effectT
+
+//====================================================================================================//
+
+// RecordEffect records an external effect, e.g., a write to a database.  kind should identify the sort
+// of effect (e.g., "sql"), and description should describe the effect itself (e.g., the statement that
+// was executed).  Effects recorded outside of the main thread, or outside of any call to WrapFuncR, are
+// ignored.
+func RecordEffect(kind string, description string) {
+	if inShadowThread() ||
+		len(mainThreadStack) <= 0 ||
+		!haveCallers(mainThreadStack[len(mainThreadStack)-1].callers) {
+		return
+	}
+	mainThreadEffects = append(mainThreadEffects, effectT{kind: kind, description: description})
+}
+
+// InShadowThread returns true iff the calling function is running in a shadow thread.
+func InShadowThread() bool {
+	return inShadowThread()
+}
+
+// ShadowRun returns a number identifying the shadow thread's current re-execution of a wrapped
+// function, or 0 if the calling function is not running in a shadow thread.  Distinct re-executions
+// are identified by distinct numbers.  Code that keeps state on behalf of shadow threads (e.g., the
+// onedgefs package) can use ShadowRun to discard the state left over from an earlier re-execution.
+func ShadowRun() uint64 {
+	if !inShadowThread() {
+		return 0
+	}
+	return shadowThreadRuns
+}
+
+//====================================================================================================//
+
+// reportEffects reports each effect recorded by the main thread since the wrappedFuncT's WrapFuncR was
+// called, and before the last checkpoint (if any).
+func reportEffects(wrappedFunc wrappedFuncT) {
+	effects := mainThreadEffects[wrappedFunc.effectsAtEntry:]
+	if wrappedFunc.checkpoints > 0 {
+		effects = mainThreadEffects[wrappedFunc.effectsAtEntry:wrappedFunc.effectsAtCheckpoint]
+	}
+	for _, effect := range effects {
+		report(
+			FindingExternalEffect,
+			"External state change between WrapFunc entry and recover (%s): %s",
+			effect.kind,
+			effect.description,
+		)
+	}
+}
+
+//====================================================================================================//
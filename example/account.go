@@ -27,6 +27,12 @@ import (
 var balance = 100
 
 func main() {
+	onedge.RegisterInvariant("balance is non-negative", func() error {
+		if balance < 0 {
+			return fmt.Errorf("balance is %d", balance)
+		}
+		return nil
+	})
 	r := rand.New(rand.NewSource(0))
 	for i := 0; i < 5; i++ {
 		if r.Intn(2) == 0 {
@@ -0,0 +1,182 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+// This file implements the CheckFileDescriptors optional check (see "checks.go").  When WrapFuncR is
+// called, the process's open file descriptors are listed (using /proc/self/fd or /dev/fd).  When
+// WrapRecover receives a panic, they are listed again, before the shadow thread re-executes the wrapped
+// function.  When WrapFuncR returns, each file descriptor that was opened between the first two
+// listings, and that is still open, is reported along with its target.  Checking only once WrapFuncR
+// returns allows deferred calls to Close that run after WrapRecover's to close their files first.
+// Go does not record where a file was opened.  But if a file is passed to RecordOpen, then the stack
+// that called RecordOpen is reported too.
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+//====================================================================================================//
+
+// openRecordT records a call to RecordOpen.
+type openRecordT struct {
+	// target is the file descriptor's target at the time of the call.  It is used to detect when the
+	// file descriptor has been closed and reused.
+	target  string
+	callers []uintptr
+}
+
+// openRecords maps file descriptors to the calls to RecordOpen that recorded them.  The records of file
+// descriptors that have since been closed are deleted by RecordOpen (see pruneOpenRecords).
+var openRecords = make(map[int]openRecordT)
+
+// openRecordsMutex protects openRecords, as RecordOpen can be called by any thread.
+var openRecordsMutex sync.Mutex
+
+//====================================================================================================//
+
+// RecordOpen records the calling stack as the place where f was opened, and returns f.  If f is later
+// reported by the CheckFileDescriptors optional check, then the recorded stack is reported too.  Calls
+// made by a shadow thread are ignored, as the shadow thread's acquisition of openRecordsMutex would
+// cause the race detector to think that the shadow thread and the main thread were synchronized.
+func RecordOpen(f *os.File) *os.File {
+	if inShadowThread() {
+		return f
+	}
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return f
+	}
+	pc := callers()
+	conn.Control(func(fd uintptr) {
+		record := openRecordT{target: fdTarget(int(fd)), callers: pc}
+		openRecordsMutex.Lock()
+		pruneOpenRecords()
+		openRecords[int(fd)] = record
+		openRecordsMutex.Unlock()
+	})
+	return f
+}
+
+// pruneOpenRecords deletes the records of file descriptors that have been closed, including those that
+// have since been reused.  openRecordsMutex must be held.
+func pruneOpenRecords() {
+	fds := openFDs()
+	if fds == nil {
+		return
+	}
+	for fd, record := range openRecords {
+		if !fds[fd] || fdTarget(fd) != record.target {
+			delete(openRecords, fd)
+		}
+	}
+}
+
+//====================================================================================================//
+
+// openFDs returns the set of open file descriptors, or nil if they cannot be listed.  The file
+// descriptor used to list the others is not included.
+func openFDs() map[int]bool {
+	dir := fdDir()
+	if dir == "" {
+		return nil
+	}
+	file, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	names, err := file.Readdirnames(-1)
+	if err != nil {
+		return nil
+	}
+	fds := make(map[int]bool)
+	for _, name := range names {
+		if fd, err := strconv.Atoi(name); err == nil {
+			fds[fd] = true
+		}
+	}
+	if conn, err := file.SyscallConn(); err == nil {
+		conn.Control(func(fd uintptr) {
+			delete(fds, int(fd))
+		})
+	}
+	return fds
+}
+
+// fdDir returns the directory that lists the process's open file descriptors, or "" if there is none.
+func fdDir() string {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+	}
+	return ""
+}
+
+// fdTarget returns the target of file descriptor fd, e.g., the name of the file that it refers to.
+func fdTarget(fd int) string {
+	target, err := os.Readlink(filepath.Join(fdDir(), strconv.Itoa(fd)))
+	if err != nil {
+		return "?"
+	}
+	return target
+}
+
+//====================================================================================================//
+
+// reportLeakedFDs reports each file descriptor that was opened between the wrappedFuncT's WrapFuncR
+// being called and a panic being received by WrapRecover, and that is still open.  File descriptors
+// opened by the shadow thread are not reported.  reportLeakedFDs is called when WrapFuncR returns.
+func reportLeakedFDs(wrappedFunc wrappedFuncT) {
+	if wrappedFunc.fdsAtEntry == nil || wrappedFunc.fdsAtRecover == nil {
+		return
+	}
+	var fds []int
+	for fd := range openFDs() {
+		if wrappedFunc.fdsAtRecover[fd] && !wrappedFunc.fdsAtEntry[fd] {
+			fds = append(fds, fd)
+		}
+	}
+	sort.Ints(fds)
+	for _, fd := range fds {
+		target := fdTarget(fd)
+		openedAt := ""
+		openRecordsMutex.Lock()
+		record, ok := openRecords[fd]
+		openRecordsMutex.Unlock()
+		if ok && record.target == target {
+			openedAt = "\n  Opened at:\n" + formatCallers(record.callers)
+		}
+		report(
+			FindingLeakedFD,
+			"File descriptor opened after WrapFunc entry is still open after recover: %d -> %s%s",
+			fd,
+			target,
+			openedAt,
+		)
+	}
+}
+
+//====================================================================================================//
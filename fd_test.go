@@ -0,0 +1,149 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+//====================================================================================================//
+
+func TestFDOpenPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "File descriptor opened after WrapFunc entry is still open after recover",
+		true)
+	checkOutput(t, output, "fd_test.go -> ", false)
+	checkOutput(t, output, "/fd_test.go\n", true)
+	checkOutput(t, output, "Opened at:", false)
+}
+
+func ExampleFDOpenPanicRecover() {
+	EnableChecks(CheckFileDescriptors)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		os.Open("fd_test.go")
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestFDRecordOpenPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "File descriptor opened after WrapFunc entry is still open after recover",
+		true)
+	checkOutput(t, output, "Opened at:", true)
+	checkOutput(t, output, "ExampleFDRecordOpenPanicRecover.func1()", true)
+}
+
+func ExampleFDRecordOpenPanicRecover() {
+	EnableChecks(CheckFileDescriptors)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		file, err := os.Open("fd_test.go")
+		if err == nil {
+			RecordOpen(file)
+		}
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestFDOpenDeferClosePanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "still open after recover", false)
+}
+
+func ExampleFDOpenDeferClosePanicRecover() {
+	EnableChecks(CheckFileDescriptors)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		file, err := os.Open("fd_test.go")
+		if err == nil {
+			defer file.Close()
+		}
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestFDOpenDeferCloseBeforeRecoverPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "still open after recover", false)
+}
+
+// The call to Close is deferred before the call to WrapRecover, and so runs after it.
+func ExampleFDOpenDeferCloseBeforeRecoverPanicRecover() {
+	EnableChecks(CheckFileDescriptors)
+	WrapFunc(func() {
+		file, err := os.Open("fd_test.go")
+		if err == nil {
+			defer file.Close()
+		}
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestFDRecordOpenClose(t *testing.T) {
+	for i := 0; i < 8; i++ {
+		file, err := os.Open("fd_test.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		RecordOpen(file)
+		file.Close()
+	}
+	openRecordsMutex.Lock()
+	n := len(openRecords)
+	openRecordsMutex.Unlock()
+	if n > 1 {
+		t.Fatalf("records of closed file descriptors were not deleted: %d", n)
+	}
+}
+
+//====================================================================================================//
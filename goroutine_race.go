@@ -0,0 +1,140 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+// This file implements the CheckGoroutines optional check (see "checks.go").  When WrapFuncR is called,
+// the ids of all existing goroutines are recorded.  When WrapFuncR returns after a panic was recovered,
+// the goroutines are examined again.  A goroutine that did not exist when WrapFuncR was called, that
+// was created by the main thread, and that is still running, is reported.  Note that shadow threads
+// are not reported: they are created by WrapFuncR, and the goroutines that they create are not created
+// by the main thread.
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+//====================================================================================================//
+
+// goroutineT is a goroutine as described by runtime.Stack.
+type goroutineT struct {
+	id     int
+	status string
+	// creator is the name of the function that created the goroutine.
+	creator string
+	// creatorID is the id of the goroutine that created the goroutine, or -1 if unknown.
+	creatorID int
+	// stack is the goroutine's stack trace, as produced by runtime.Stack.
+	stack string
+}
+
+var (
+	goroutineHeaderRegexp = regexp.MustCompile(`^goroutine ([0-9]+) \[([^\]]*)\]:$`)
+	createdByRegexp       = regexp.MustCompile(`^created by (\S+)(?: in goroutine ([0-9]+))?$`)
+)
+
+// wrapFuncROptsName is the name of the function that creates shadow threads.  It is set by init, as
+// setting it here would create an initialization cycle.
+var wrapFuncROptsName string
+
+func init() {
+	wrapFuncROptsName = runtime.FuncForPC(reflect.ValueOf(WrapFuncROpts).Pointer()).Name()
+}
+
+//====================================================================================================//
+
+// goroutineIDs returns the set of ids of all existing goroutines.
+func goroutineIDs() map[int]bool {
+	ids := make(map[int]bool)
+	for _, goroutine := range goroutines(true) {
+		ids[goroutine.id] = true
+	}
+	return ids
+}
+
+// currentGoroutineID returns the id of the calling goroutine.
+func currentGoroutineID() int {
+	return goroutines(false)[0].id
+}
+
+//====================================================================================================//
+
+// reportLeakedGoroutines reports each goroutine that did not exist when the wrappedFuncT's WrapFuncR
+// was called, that was created by the main thread, and that is still running.
+func reportLeakedGoroutines(wrappedFunc wrappedFuncT) {
+	for _, goroutine := range goroutines(true) {
+		if wrappedFunc.goroutinesAtEntry[goroutine.id] ||
+			goroutine.id == wrappedFunc.goroutineID ||
+			goroutine.creator == wrapFuncROptsName ||
+			(goroutine.creatorID >= 0 && goroutine.creatorID != wrappedFunc.goroutineID) {
+			continue
+		}
+		report(
+			FindingLeakedGoroutine,
+			"Goroutine started after WrapFunc entry is still running after recover "+
+				"(goroutine %d [%s]):\n%s",
+			goroutine.id,
+			goroutine.status,
+			prefixLines("    ", goroutine.stack),
+		)
+	}
+}
+
+//====================================================================================================//
+
+// goroutines returns the goroutines described by runtime.Stack.  If all is false, only the calling
+// goroutine is returned.
+func goroutines(all bool) []goroutineT {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, all)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	var result []goroutineT
+	for _, block := range strings.Split(strings.TrimSpace(string(buf)), "\n\n") {
+		lines := strings.Split(block, "\n")
+		match := goroutineHeaderRegexp.FindStringSubmatch(lines[0])
+		if match == nil {
+			continue
+		}
+		goroutine := goroutineT{status: match[2], creatorID: -1, stack: block}
+		goroutine.id, _ = strconv.Atoi(match[1])
+		for _, line := range lines[1:] {
+			if match := createdByRegexp.FindStringSubmatch(line); match != nil {
+				goroutine.creator = match[1]
+				if match[2] != "" {
+					goroutine.creatorID, _ = strconv.Atoi(match[2])
+				}
+			}
+		}
+		result = append(result, goroutine)
+	}
+	return result
+}
+
+//====================================================================================================//
@@ -0,0 +1,108 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+//====================================================================================================//
+
+func TestGoroutineStartPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "Goroutine started after WrapFunc entry is still running after recover",
+		true)
+	checkOutput(t, output,
+		"created by github.com/trailofbits/on-edge.ExampleGoroutineStartPanicRecover.func1", true)
+}
+
+func ExampleGoroutineStartPanicRecover() {
+	EnableChecks(CheckGoroutines)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		c := make(chan struct{})
+		go func() {
+			<-c
+		}()
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestGoroutineStartWaitPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "still running after recover", false)
+}
+
+func ExampleGoroutineStartWaitPanicRecover() {
+	EnableChecks(CheckGoroutines)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		ids := make(chan int)
+		go func() {
+			ids <- currentGoroutineID()
+		}()
+		// The goroutine may still exist after the send, so wait until it has returned.
+		id := <-ids
+		for goroutineIDs()[id] {
+			runtime.Gosched()
+		}
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestGoroutineStartPanicRecoverDisabled(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "still running after recover", false)
+}
+
+func ExampleGoroutineStartPanicRecoverDisabled() {
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		c := make(chan struct{})
+		go func() {
+			<-c
+		}()
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
@@ -0,0 +1,78 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// This file contains OnEdge's lifecycle hooks, which allow tools (e.g., custom logging, metrics, or
+// tracing) to be built on top of OnEdge.  Like the rest of OnEdge, the hooks are invoked only when Go's
+// race detector is enabled.
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"strconv"
+)
+
+//====================================================================================================//
+
+// Site is the location of a call to WrapFunc, or one of its variants.
+type Site struct {
+	File string
+	Line int
+}
+
+// String returns site in the form "file:line".
+//
+// String is called by shadow threads (see profileLabels in "trace_race.go"), and so it must not use
+// fmt.  In race builds, fmt's printers are recycled through a sync.Pool, and getting a printer that the
+// main thread put causes the race detector to think that the two threads are synchronized.  Data races
+// in the wrapped function can then go unreported.
+func (site Site) String() string {
+	if site.File == "" {
+		return "<unknown>"
+	}
+	return site.File + ":" + strconv.Itoa(site.Line)
+}
+
+//====================================================================================================//
+
+// Hooks are callbacks invoked at points in the lifecycle of a call to WrapFunc, or one of its variants.
+// Nil callbacks are skipped.  Each callback is invoked by the main thread, never by a shadow thread.
+// OnPanic and OnShadowStart are invoked while the race detector's handling of synchronization is
+// disabled, so that any synchronization that they perform (e.g., printing) does not hide data races
+// between the main thread and the shadow thread.
+type Hooks struct {
+	// OnEnter is invoked on entry to the wrapped function.
+	OnEnter func(site Site)
+	// OnPanic is invoked when WrapRecover receives a panic with argument r.
+	OnPanic func(site Site, r interface{})
+	// OnShadowStart is invoked immediately before the shadow thread re-executes the wrapped function.
+	OnShadowStart func(site Site)
+	// OnShadowResult is invoked for each recover result (shadowR) forwarded by the shadow thread.
+	OnShadowResult func(site Site, shadowR interface{})
+	// OnExit is invoked when the wrapped function returns.
+	OnExit func(site Site)
+}
+
+// hooks are the Hooks set by SetHooks.
+var hooks Hooks
+
+// SetHooks sets the hooks invoked by OnEdge, replacing any set previously.
+func SetHooks(h Hooks) {
+	hooks = h
+}
+
+//====================================================================================================//
@@ -0,0 +1,57 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build !race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"testing"
+)
+
+//====================================================================================================//
+
+// TestHooksNoRace checks that hooks are not invoked by the "no-race" version of OnEdge.
+func TestHooksNoRace(t *testing.T) {
+	invoked := false
+	SetHooks(Hooks{
+		OnEnter: func(site Site) {
+			invoked = true
+		},
+		OnPanic: func(site Site, r interface{}) {
+			invoked = true
+		},
+		OnExit: func(site Site) {
+			invoked = true
+		},
+	})
+	defer SetHooks(Hooks{})
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		panic(fmt.Errorf(""))
+	})
+	if invoked {
+		t.Fatal("hook invoked")
+	}
+}
+
+//====================================================================================================//
@@ -0,0 +1,63 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+// This file contains the functions that invoke the hooks set by SetHooks (see "hooks.go").  Each is
+// called only by the main thread.
+
+//====================================================================================================//
+
+package onedge
+
+//====================================================================================================//
+
+// invokeOnEnter invokes the OnEnter hook, if set.
+func invokeOnEnter(site Site) {
+	if hooks.OnEnter != nil {
+		hooks.OnEnter(site)
+	}
+}
+
+// invokeOnPanic invokes the OnPanic hook, if set.
+func invokeOnPanic(site Site, r interface{}) {
+	if hooks.OnPanic != nil {
+		hooks.OnPanic(site, r)
+	}
+}
+
+// invokeOnShadowStart invokes the OnShadowStart hook, if set.
+func invokeOnShadowStart(site Site) {
+	if hooks.OnShadowStart != nil {
+		hooks.OnShadowStart(site)
+	}
+}
+
+// invokeOnShadowResult invokes the OnShadowResult hook, if set.
+func invokeOnShadowResult(site Site, shadowR interface{}) {
+	if hooks.OnShadowResult != nil {
+		hooks.OnShadowResult(site, shadowR)
+	}
+}
+
+// invokeOnExit invokes the OnExit hook, if set.
+func invokeOnExit(site Site) {
+	if hooks.OnExit != nil {
+		hooks.OnExit(site)
+	}
+}
+
+//====================================================================================================//
@@ -0,0 +1,131 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+//====================================================================================================//
+
+func TestHooksPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+}
+
+func ExampleHooksPanicRecover() {
+	SetHooks(Hooks{
+		OnEnter: func(site Site) {
+			fmt.Println("enter", filepath.Base(site.File))
+		},
+		OnPanic: func(site Site, r interface{}) {
+			fmt.Println("panic", r)
+		},
+		OnShadowStart: func(site Site) {
+			fmt.Println("shadow start")
+		},
+		OnShadowResult: func(site Site, shadowR interface{}) {
+			fmt.Println("shadow result", shadowR)
+		},
+		OnExit: func(site Site) {
+			fmt.Println("exit")
+		},
+	})
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		panic(fmt.Errorf("x"))
+	})
+	// Output:
+	// enter hooks_test.go
+	// panic x
+	// shadow start
+	// shadow result x
+	// exit
+}
+
+//====================================================================================================//
+
+func TestHooksNoPanic(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+}
+
+func ExampleHooksNoPanic() {
+	SetHooks(Hooks{
+		OnEnter: func(site Site) {
+			fmt.Println("enter")
+		},
+		OnPanic: func(site Site, r interface{}) {
+			fmt.Println("panic", r)
+		},
+		OnExit: func(site Site) {
+			fmt.Println("exit")
+		},
+	})
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+	})
+	// Output:
+	// enter
+	// exit
+}
+
+//====================================================================================================//
+
+func TestHooksPrintIncrementPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+}
+
+// The hooks' printing must not cause the race detector to think that the shadow thread and the main
+// thread were synchronized.
+func ExampleHooksPrintIncrementPanicRecover() {
+	SetHooks(Hooks{
+		OnPanic: func(site Site, r interface{}) {
+			fmt.Println("panic", r)
+		},
+		OnShadowStart: func(site Site) {
+			fmt.Println("shadow start")
+		},
+	})
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		fmt.Print("")
+		exampleCounter++
+		panic(fmt.Errorf("x"))
+	})
+	// Output:
+	// panic x
+	// shadow start
+}
+
+//====================================================================================================//
@@ -0,0 +1,54 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+// This file contains OnEdge's idempotence check (see CheckIdempotence in "checks.go").  When the check
+// is enabled and a wrapped function returns without a panic having been recovered, the function's
+// shadow thread is told to call the function again.  Global state changes made by the first call that
+// affect the second then appear as data races, just as they do when a panic is recovered.  Moreover,
+// the results of the two calls are compared.
+
+//====================================================================================================//
+
+package onedge
+
+//====================================================================================================//
+
+// checkIdempotence re-executes the wrappedFuncT's function in its shadow thread, and reports any panic
+// or difference from result, the main thread's result.
+func checkIdempotence(wrappedFunc wrappedFuncT, result interface{}) {
+	shadowRs, shadowResult := runShadowThread(wrappedFunc, 0)
+	for _, shadowR := range shadowRs {
+		if shadowR != nil {
+			report(
+				FindingNotIdempotent,
+				"Shadow thread panicked on re-execution after successful call: %v",
+				shadowR,
+			)
+		}
+	}
+	if !comparator(result, shadowResult) {
+		report(
+			FindingNotIdempotent,
+			"Shadow thread returned different result on re-execution: %v != %v",
+			result,
+			shadowResult,
+		)
+	}
+}
+
+//====================================================================================================//
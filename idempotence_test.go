@@ -0,0 +1,88 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"testing"
+)
+
+//====================================================================================================//
+
+var exampleRetries int
+
+//====================================================================================================//
+
+func TestIdempotenceIncrementReturn(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+	checkOutput(t, output, "Shadow thread returned different result on re-execution: 1 != 2", true)
+}
+
+func ExampleIdempotenceIncrementReturn() {
+	WrapFuncROpts(Options{Checks: CheckIdempotence}, func() interface{} {
+		exampleRetries++
+		return exampleRetries
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestIdempotenceIncrementPanicIfRetried(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+	checkOutput(t, output, "Shadow thread panicked on re-execution after successful call: retried",
+		true)
+}
+
+func ExampleIdempotenceIncrementPanicIfRetried() {
+	EnableChecks(CheckIdempotence)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		exampleRetries++
+		if exampleRetries >= 2 {
+			panic(fmt.Errorf("retried"))
+		}
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestIdempotenceReturn(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "re-execution", false)
+}
+
+func ExampleIdempotenceReturn() {
+	EnableChecks(CheckIdempotence)
+	fmt.Println(WrapFuncR(func() interface{} {
+		return 1
+	}))
+	// Output: 1
+}
+
+//====================================================================================================//
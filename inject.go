@@ -0,0 +1,214 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// This file contains OnEdge's panic injection support.  Calls to InjectionPoint can be placed in
+// production code, where they do nothing unless RunInjection is running.  RunInjection runs a workload
+// once to discover the injection points that it reaches, and then once for each of a number of
+// schedules, i.e., sets of injection points that panic.  The findings, data races, and escaped panics
+// produced under each schedule are returned, so that the schedules that exercise a faulty recover path
+// can be identified.
+//   An injection point in a schedule panics each time that it is reached, in the main thread and in
+// the shadow thread alike.  Thus, the shadow thread panics at the same point as the main thread.
+//   The schedules are tried in the calling goroutine, one after another.  RunInjection should not be
+// called concurrently with itself, or with other code that calls InjectionPoint.
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+//====================================================================================================//
+
+// InjectedPanic is the value with which an injection point panics.
+type InjectedPanic struct {
+	Name string
+}
+
+func (injectedPanic InjectedPanic) Error() string {
+	return "onedge: injected panic at " + injectedPanic.Name
+}
+
+// InjectionMode determines the schedules that RunInjection tries.
+type InjectionMode int
+
+const (
+	// InjectOneAtATime tries each schedule consisting of exactly one injection point.
+	InjectOneAtATime InjectionMode = iota
+	// InjectExhaustive tries every non-empty set of injection points.  Note that there are 2^n - 1 such
+	// sets, where n is the number of injection points.  RunInjection returns an error if n exceeds
+	// MaxExhaustivePoints.
+	InjectExhaustive
+	// InjectRandom tries InjectionOptions.Schedules randomly chosen sets of injection points, each of
+	// which contains each injection point with probability 1/2.
+	InjectRandom
+)
+
+// MaxExhaustivePoints is the largest number of injection points for which InjectExhaustive can be used.
+const MaxExhaustivePoints = 20
+
+// InjectionOptions are options for RunInjection.
+type InjectionOptions struct {
+	Mode InjectionMode
+	// Seed seeds the random choices made by InjectRandom.  Runs with the same seed, and the same
+	// injection points, try the same schedules.
+	Seed int64
+	// Schedules is the number of schedules tried by InjectRandom.
+	Schedules int
+}
+
+// Schedule is a set of injection points that panic, by name, in the order in which they were first
+// reached.
+type Schedule []string
+
+// ScheduleResult is the result of running a workload under one schedule.
+type ScheduleResult struct {
+	Schedule Schedule
+	// Findings contains the findings reported while the workload ran.
+	Findings []Finding
+	// DataRaces is the number of data races reported by Go's race detector while the workload ran.
+	DataRaces int
+	// Panic is the argument of a panic that escaped the workload, or nil if there was none.
+	Panic interface{}
+}
+
+// Failed returns true iff any findings or data races were reported under the schedule.  A panic that
+// escaped the workload is not a failure in itself, as the workload may not be meant to recover.
+func (result ScheduleResult) Failed() bool {
+	return len(result.Findings) > 0 || result.DataRaces > 0
+}
+
+// injection is the state of the currently running RunInjection, if any.
+var injection struct {
+	enabled bool
+	// schedule contains the injection points that panic.
+	schedule map[string]bool
+	// reached contains the injection points reached by the main thread, in the order in which they
+	// were first reached, and seen is the same set as a map.
+	reached []string
+	seen    map[string]bool
+}
+
+//====================================================================================================//
+
+// InjectionPoint marks a point at which RunInjection can inject a panic.  name identifies the point,
+// and should be unique.  InjectionPoint does nothing unless RunInjection is running, in which case it
+// panics with an InjectedPanic iff the point is in the current schedule.
+func InjectionPoint(name string) {
+	if !injection.enabled {
+		return
+	}
+	recordInjectionPoint(name)
+	if injection.schedule[name] {
+		panic(InjectedPanic{Name: name})
+	}
+}
+
+// RunInjection runs workload once with no injected panics, to discover the injection points that it
+// reaches, and then once for each schedule determined by opts.  workload should call WrapFunc (or one
+// of its variants) itself, or call code that does.  Each finding is reported as usual, in addition to
+// being included in the schedule's result.  Injection points reached only after an injected panic are
+// not included in any schedule.  An error is returned if opts.Mode is InjectExhaustive and more than
+// MaxExhaustivePoints injection points are reached, in which case no schedules are tried.
+func RunInjection(opts InjectionOptions, workload func()) ([]ScheduleResult, error) {
+	injection.enabled = true
+	injection.schedule = nil
+	injection.reached = nil
+	injection.seen = make(map[string]bool)
+	defer func() {
+		injection.enabled = false
+		injection.schedule = nil
+	}()
+	workload()
+	schedules, err := injectionSchedules(opts, injection.reached)
+	if err != nil {
+		return nil, err
+	}
+	var results []ScheduleResult
+	for _, schedule := range schedules {
+		results = append(results, runSchedule(schedule, workload))
+	}
+	return results, nil
+}
+
+//====================================================================================================//
+
+// injectionSchedules returns the schedules determined by opts, given the injection points reached.
+func injectionSchedules(opts InjectionOptions, points []string) ([]Schedule, error) {
+	var schedules []Schedule
+	switch opts.Mode {
+	case InjectOneAtATime:
+		for _, point := range points {
+			schedules = append(schedules, Schedule{point})
+		}
+	case InjectExhaustive:
+		if len(points) > MaxExhaustivePoints {
+			return nil, fmt.Errorf(
+				"onedge: InjectExhaustive supports at most %d injection points, but %d were reached",
+				MaxExhaustivePoints,
+				len(points),
+			)
+		}
+		for mask := uint64(1); mask < uint64(1)<<uint(len(points)); mask++ {
+			var schedule Schedule
+			for i, point := range points {
+				if mask&(uint64(1)<<uint(i)) != 0 {
+					schedule = append(schedule, point)
+				}
+			}
+			schedules = append(schedules, schedule)
+		}
+	case InjectRandom:
+		rng := rand.New(rand.NewSource(opts.Seed))
+		for i := 0; i < opts.Schedules; i++ {
+			schedule := Schedule{}
+			for _, point := range points {
+				if rng.Intn(2) != 0 {
+					schedule = append(schedule, point)
+				}
+			}
+			schedules = append(schedules, schedule)
+		}
+	}
+	return schedules, nil
+}
+
+// runSchedule runs workload under schedule, and returns the result.
+func runSchedule(schedule Schedule, workload func()) (result ScheduleResult) {
+	result.Schedule = schedule
+	injection.schedule = make(map[string]bool, len(schedule))
+	for _, point := range schedule {
+		injection.schedule[point] = true
+	}
+	var prev Reporter
+	prev = SetReporter(func(finding Finding) {
+		result.Findings = append(result.Findings, finding)
+		prev(finding)
+	})
+	defer SetReporter(prev)
+	dataRacesAtStart := dataRaces()
+	defer func() {
+		result.DataRaces = dataRaces() - dataRacesAtStart
+		result.Panic = recover()
+	}()
+	workload()
+	return
+}
+
+//====================================================================================================//
@@ -0,0 +1,42 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build !race
+
+// This is the "no-race" version of the build-specific parts of OnEdge's panic injection support (see
+// "inject.go").  Without Go's race detector, there are no shadow threads and no data race reports.
+
+//====================================================================================================//
+
+package onedge
+
+//====================================================================================================//
+
+// recordInjectionPoint records that the named injection point was reached.
+func recordInjectionPoint(name string) {
+	if injection.seen[name] {
+		return
+	}
+	injection.seen[name] = true
+	injection.reached = append(injection.reached, name)
+}
+
+// dataRaces returns 0.
+func dataRaces() int {
+	return 0
+}
+
+//====================================================================================================//
@@ -0,0 +1,82 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build !race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"testing"
+)
+
+//====================================================================================================//
+
+// TestInjectionNoRace checks that the "no-race" version of OnEdge injects panics, but reports no
+// findings or data races.
+func TestInjectionNoRace(t *testing.T) {
+	results, err := RunInjection(InjectionOptions{Mode: InjectOneAtATime}, func() {
+		WrapFunc(func() {
+			defer func() {
+				if r := WrapRecover(recover()); r != nil {
+				}
+			}()
+			InjectionPoint("a")
+			InjectionPoint("b")
+		})
+		InjectionPoint("c")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := fmt.Sprintf("%v", results)
+	expected := "[{[a] [] 0 <nil>} {[b] [] 0 <nil>} {[c] [] 0 onedge: injected panic at c}]"
+	if actual != expected {
+		t.Fatalf("unexpected results: %s", actual)
+	}
+	InjectionPoint("a")
+}
+
+// TestInjectionRandomNoRace checks that random schedules are determined by the seed.
+func TestInjectionRandomNoRace(t *testing.T) {
+	workload := func() {
+		for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+			InjectionPoint(name)
+		}
+	}
+	schedules := func(seed int64) string {
+		var schedules []Schedule
+		opts := InjectionOptions{Mode: InjectRandom, Seed: seed, Schedules: 8}
+		results, err := RunInjection(opts, workload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, result := range results {
+			schedules = append(schedules, result.Schedule)
+		}
+		return fmt.Sprintf("%v", schedules)
+	}
+	if schedules(1) != schedules(1) {
+		t.Fatal("schedules differ for the same seed")
+	}
+	if schedules(1) == schedules(2) {
+		t.Fatal("schedules are the same for different seeds")
+	}
+}
+
+//====================================================================================================//
@@ -0,0 +1,49 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+// This is the "race" version of the build-specific parts of OnEdge's panic injection support (see
+// "inject.go").
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"runtime"
+)
+
+//====================================================================================================//
+
+// recordInjectionPoint records that the main thread reached the named injection point.  Injection
+// points reached by a shadow thread are not recorded.
+func recordInjectionPoint(name string) {
+	// The shadow thread must not write to injection, as doing so would race with the main thread's
+	// writes.  Such a race would be reported as though it were in the code under test.
+	if inShadowThread() || injection.seen[name] {
+		return
+	}
+	injection.seen[name] = true
+	injection.reached = append(injection.reached, name)
+}
+
+// dataRaces returns the number of data races reported by Go's race detector so far.
+func dataRaces() int {
+	return runtime.RaceErrors()
+}
+
+//====================================================================================================//
@@ -0,0 +1,138 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"testing"
+)
+
+//====================================================================================================//
+
+// injectionWorkload records an external effect after injection point "a", and modifies a global after
+// injection point "b".
+func injectionWorkload() {
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		InjectionPoint("a")
+		RecordEffect("test", "effect")
+		InjectionPoint("b")
+		exampleCounter++
+		InjectionPoint("c")
+	})
+}
+
+// printResults prints the number of findings and data races for each schedule, or err if it is not
+// nil.
+func printResults(results []ScheduleResult, err error) {
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, result := range results {
+		fmt.Printf(
+			"%v: findings %d, data races %d, panic %v, failed %v\n",
+			result.Schedule,
+			len(result.Findings),
+			result.DataRaces,
+			result.Panic,
+			result.Failed(),
+		)
+	}
+}
+
+//====================================================================================================//
+
+func TestInjectionOneAtATime(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+	checkOutput(t, output,
+		"=== External state change between WrapFunc entry and recover (test): effect", true)
+	checkOutput(t, output, "--- PASS: ExampleInjectionOneAtATime", true)
+}
+
+func ExampleInjectionOneAtATime() {
+	printResults(RunInjection(InjectionOptions{Mode: InjectOneAtATime}, injectionWorkload))
+	// Output:
+	// [a]: findings 0, data races 0, panic <nil>, failed false
+	// [b]: findings 1, data races 0, panic <nil>, failed true
+	// [c]: findings 1, data races 1, panic <nil>, failed true
+}
+
+//====================================================================================================//
+
+func TestInjectionExhaustive(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+	checkOutput(t, output, "--- PASS: ExampleInjectionExhaustive", true)
+}
+
+func ExampleInjectionExhaustive() {
+	printResults(RunInjection(InjectionOptions{Mode: InjectExhaustive}, injectionWorkload))
+	// Output:
+	// [a]: findings 0, data races 0, panic <nil>, failed false
+	// [b]: findings 1, data races 0, panic <nil>, failed true
+	// [a b]: findings 0, data races 0, panic <nil>, failed false
+	// [c]: findings 1, data races 1, panic <nil>, failed true
+	// [a c]: findings 0, data races 0, panic <nil>, failed false
+	// [b c]: findings 1, data races 0, panic <nil>, failed true
+	// [a b c]: findings 0, data races 0, panic <nil>, failed false
+}
+
+//====================================================================================================//
+
+func TestInjectionExhaustiveTooMany(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "--- PASS: ExampleInjectionExhaustiveTooMany", true)
+}
+
+func ExampleInjectionExhaustiveTooMany() {
+	printResults(RunInjection(InjectionOptions{Mode: InjectExhaustive}, func() {
+		for i := 0; i <= MaxExhaustivePoints; i++ {
+			InjectionPoint(fmt.Sprintf("p%d", i))
+		}
+	}))
+	// Output:
+	// onedge: InjectExhaustive supports at most 20 injection points, but 21 were reached
+}
+
+//====================================================================================================//
+
+func TestInjectionUnrecovered(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+}
+
+func ExampleInjectionUnrecovered() {
+	printResults(RunInjection(InjectionOptions{Mode: InjectOneAtATime}, func() {
+		WrapFunc(func() {
+			InjectionPoint("a")
+		})
+	}))
+	// Output:
+	// [a]: findings 0, data races 0, panic onedge: injected panic at a, failed false
+}
+
+//====================================================================================================//
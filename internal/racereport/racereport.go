@@ -0,0 +1,246 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// Package racereport parses the reports printed by Go's race detector.  Each report looks something
+// like the following.
+//
+//	==================
+//	WARNING: DATA RACE
+//	Write at 0x000001b287ad by goroutine 9:
+//	  main.f()
+//	      /path/to/main.go:10 +0x3d
+//	  ...
+//
+//	Previous write at 0x000001b287ad by main goroutine:
+//	  main.f()
+//	      /path/to/main.go:10 +0x3d
+//	  ...
+//
+//	Goroutine 9 (running) created at:
+//	  ...
+//	==================
+//
+// Only the accesses are parsed.  The stacks of the goroutines' creations are ignored.
+package racereport
+
+//====================================================================================================//
+
+import (
+	"bufio"
+	"debug/elf"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/trailofbits/on-edge/internal/testname"
+)
+
+//====================================================================================================//
+
+// Frame is a frame of a stack in a report.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// Access is one of the two accesses involved in a data race.
+type Access struct {
+	// Op is the kind of access, e.g., "Read", "Write", or "Atomic write".  The "Previous" of the second
+	// access is removed.
+	Op   string
+	Addr uint64
+	// Goroutine describes the accessing goroutine, e.g., "goroutine 9" or "main goroutine".
+	Goroutine string
+	Stack     []Frame
+}
+
+// Report is a data race report.
+type Report struct {
+	// Accesses contains the current access, followed by the previous access (if the race detector was
+	// able to restore its stack).
+	Accesses []Access
+	// Text is the report as printed, including its delimiters.
+	Text string
+}
+
+// Test returns the name of the Test, Benchmark, or Fuzz function that was running on the stack of
+// either access, or the empty string if there is none.  A shadow thread's stack does not include the
+// test, but the main thread's does.
+func (report Report) Test() string {
+	for _, access := range report.Accesses {
+		functions := make([]string, len(access.Stack))
+		for i, frame := range access.Stack {
+			functions[i] = frame.Function
+		}
+		if name := testname.FromStack(functions); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// WrapFuncCaller returns the frame of the enclosing most call to WrapFunc (or one of its variants) on
+// the access's stack, if any.  A shadow thread's stack contains no such call, but the main thread's
+// does.  The frames of function literals within WrapFunc (e.g., "WrapFuncROpts.func2") are not calls
+// to WrapFunc.
+func (access Access) WrapFuncCaller() (Frame, bool) {
+	for i := 0; i+1 < len(access.Stack); i++ {
+		name := strings.TrimPrefix(access.Stack[i].Function, onedgePrefix)
+		if name != access.Stack[i].Function &&
+			strings.HasPrefix(name, "WrapFunc") &&
+			!strings.Contains(name, ".") &&
+			!strings.HasPrefix(access.Stack[i+1].Function, onedgePrefix) {
+			return access.Stack[i+1], true
+		}
+	}
+	return Frame{}, false
+}
+
+// InShadowThread returns true iff the access was made by a shadow thread.
+func (access Access) InShadowThread() bool {
+	for _, frame := range access.Stack {
+		if strings.HasPrefix(frame.Function, onedgePrefix+"shadowThread") {
+			return true
+		}
+	}
+	return false
+}
+
+//====================================================================================================//
+
+// onedgePrefix prefixes the names of the functions in the onedge package.
+const onedgePrefix = "github.com/trailofbits/on-edge."
+
+// delimiter is the line printed before and after each report.
+const delimiter = "=================="
+
+var (
+	accessRegexp = regexp.MustCompile(
+		`^(?:Previous )?([A-Za-z ]+?)(?: of size \d+)? at 0x([0-9a-f]+) ` +
+			`by ((?:main )?goroutine(?: \d+)?):$`,
+	)
+	fileRegexp = regexp.MustCompile(`^\s+(.*):(\d+)(?: \+0x[0-9a-f]+)?$`)
+)
+
+//====================================================================================================//
+
+// Parse returns the data race reports contained in output.  Lines of output that are not part of a
+// report are ignored.
+func Parse(output string) []Report {
+	var reports []Report
+	var lines []string
+	inReport := false
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != delimiter {
+			if inReport {
+				lines = append(lines, line)
+			}
+			continue
+		}
+		if inReport && len(lines) > 0 && lines[0] == "WARNING: DATA RACE" {
+			reports = append(reports, parseReport(lines))
+			lines = nil
+			inReport = false
+			continue
+		}
+		lines = nil
+		inReport = true
+	}
+	return reports
+}
+
+// parseReport parses the lines between a report's delimiters.
+func parseReport(lines []string) Report {
+	report := Report{Text: delimiter + "\n" + strings.Join(lines, "\n") + "\n" + delimiter + "\n"}
+	var access *Access
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if m := accessRegexp.FindStringSubmatch(line); m != nil {
+			addr, _ := strconv.ParseUint(m[2], 16, 64)
+			report.Accesses = append(report.Accesses, Access{
+				Op:        strings.ToUpper(m[1][:1]) + m[1][1:],
+				Addr:      addr,
+				Goroutine: m[3],
+			})
+			access = &report.Accesses[len(report.Accesses)-1]
+			continue
+		}
+		if line == "" || !strings.HasPrefix(line, "  ") {
+			access = nil
+			continue
+		}
+		if access == nil || i+1 >= len(lines) {
+			continue
+		}
+		m := fileRegexp.FindStringSubmatch(lines[i+1])
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[2])
+		access.Stack = append(access.Stack, Frame{
+			Function: strings.TrimSuffix(strings.TrimSpace(line), "()"),
+			File:     m[1],
+			Line:     n,
+		})
+		i++
+	}
+	return report
+}
+
+//====================================================================================================//
+
+// Symbolizer maps addresses to the names of the global variables containing them, using the symbol
+// table of an executable.
+type Symbolizer struct {
+	symbols []elf.Symbol
+}
+
+// NewSymbolizer returns a Symbolizer for the executable at path.  Only ELF executables are supported.
+func NewSymbolizer(path string) (*Symbolizer, error) {
+	file, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	symbols, err := file.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	var objects []elf.Symbol
+	for _, symbol := range symbols {
+		if elf.ST_TYPE(symbol.Info) == elf.STT_OBJECT && symbol.Size > 0 {
+			objects = append(objects, symbol)
+		}
+	}
+	return &Symbolizer{symbols: objects}, nil
+}
+
+// Lookup returns the name of the global variable containing addr.  ok is false if there is no such
+// variable, e.g., because addr is on the heap.
+func (s *Symbolizer) Lookup(addr uint64) (name string, ok bool) {
+	for _, symbol := range s.symbols {
+		if symbol.Value <= addr && addr < symbol.Value+symbol.Size {
+			return symbol.Name, true
+		}
+	}
+	return "", false
+}
+
+//====================================================================================================//
@@ -0,0 +1,123 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+package racereport
+
+//====================================================================================================//
+
+import (
+	"reflect"
+	"testing"
+)
+
+//====================================================================================================//
+
+const exampleOutput = `=== RUN   TestExample
+==================
+WARNING: DATA RACE
+Write at 0x000001b287ad by goroutine 9:
+  main.f.func1()
+      /path/to/main.go:88 +0x3d
+  main.g()
+      /path/to/main.go:183 +0x2e
+
+Previous read at 0x000001b287ad by main goroutine:
+  main.f.func1()
+      /path/to/main.go:87 +0x3d
+  main.TestExample()
+      /path/to/main_test.go:10 +0x1c
+  testing.tRunner()
+      /usr/local/go/src/testing/testing.go:1792 +0x225
+
+Goroutine 9 (running) created at:
+  main.h()
+      /path/to/main.go:273 +0x8d2
+==================
+--- FAIL: TestExample (0.00s)
+    testing.go:1490: race detected during execution of test
+`
+
+//====================================================================================================//
+
+func TestParse(t *testing.T) {
+	reports := Parse(exampleOutput)
+	if len(reports) != 1 {
+		t.Fatalf("unexpected reports: %+v", reports)
+	}
+	expected := []Access{
+		{
+			Op:        "Write",
+			Addr:      0x1b287ad,
+			Goroutine: "goroutine 9",
+			Stack: []Frame{
+				{Function: "main.f.func1", File: "/path/to/main.go", Line: 88},
+				{Function: "main.g", File: "/path/to/main.go", Line: 183},
+			},
+		},
+		{
+			Op:        "Read",
+			Addr:      0x1b287ad,
+			Goroutine: "main goroutine",
+			Stack: []Frame{
+				{Function: "main.f.func1", File: "/path/to/main.go", Line: 87},
+				{Function: "main.TestExample", File: "/path/to/main_test.go", Line: 10},
+				{Function: "testing.tRunner", File: "/usr/local/go/src/testing/testing.go", Line: 1792},
+			},
+		},
+	}
+	if !reflect.DeepEqual(reports[0].Accesses, expected) {
+		t.Fatalf("unexpected accesses: %+v", reports[0].Accesses)
+	}
+	if name := reports[0].Test(); name != "TestExample" {
+		t.Fatalf("unexpected test: %q", name)
+	}
+}
+
+func TestParseNoReports(t *testing.T) {
+	if reports := Parse("==================\nnot a report\n==================\n"); len(reports) != 0 {
+		t.Fatalf("unexpected reports: %+v", reports)
+	}
+}
+
+//====================================================================================================//
+
+func TestWrapFuncCaller(t *testing.T) {
+	main := Access{Stack: []Frame{
+		{Function: "p.f.func1", File: "/p/p.go", Line: 8},
+		{Function: onedgePrefix + "WrapFunc.func1", File: "/on-edge/onedge_race.go", Line: 183},
+		{Function: onedgePrefix + "WrapFuncROpts.func2", File: "/on-edge/onedge_race.go", Line: 279},
+		{Function: "runtime/pprof.Do", File: "/go/src/runtime/pprof/runtime.go", Line: 57},
+		{Function: onedgePrefix + "WrapFuncROpts", File: "/on-edge/onedge_race.go", Line: 277},
+		{Function: onedgePrefix + "WrapFunc", File: "/on-edge/onedge_race.go", Line: 182},
+		{Function: "p.f", File: "/p/p.go", Line: 7},
+	}}
+	shadow := Access{Stack: []Frame{
+		{Function: "p.f.func1", File: "/p/p.go", Line: 8},
+		{Function: onedgePrefix + "WrapFunc.func1", File: "/on-edge/onedge_race.go", Line: 183},
+		{Function: onedgePrefix + "shadowThread", File: "/on-edge/onedge_race.go", Line: 513},
+	}}
+	if caller, ok := main.WrapFuncCaller(); !ok || caller != main.Stack[6] {
+		t.Fatalf("unexpected caller: %+v, %v", caller, ok)
+	}
+	if caller, ok := shadow.WrapFuncCaller(); ok {
+		t.Fatalf("unexpected caller: %+v", caller)
+	}
+	if main.InShadowThread() || !shadow.InShadowThread() {
+		t.Fatal("unexpected thread")
+	}
+}
+
+//====================================================================================================//
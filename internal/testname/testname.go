@@ -0,0 +1,77 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// Package testname determines the Go test (i.e., the Test, Benchmark, or Fuzz function) that is running
+// on a stack.
+package testname
+
+//====================================================================================================//
+
+import (
+	"strings"
+)
+
+//====================================================================================================//
+
+// prefixes are the prefixes of the names of the functions run by the testing package.
+var prefixes = []string{"Test", "Benchmark", "Fuzz"}
+
+//====================================================================================================//
+
+// FromStack returns the name of the Test, Benchmark, or Fuzz function running on a stack, or the empty
+// string if there is none.  functions contains the fully qualified names of the stack's functions,
+// innermost first.  The function is found by looking for the outermost test function that was
+// called, directly or indirectly, by the testing package (e.g., by testing.tRunner).  For a subtest,
+// or for a closure within a test function, the name of the enclosing top-level function is returned.
+func FromStack(functions []string) string {
+	name := ""
+	for _, function := range functions {
+		pkg, rest := split(function)
+		if pkg == "testing" {
+			if name != "" {
+				return name
+			}
+			continue
+		}
+		if top := strings.SplitN(rest, ".", 2)[0]; isTestName(top) {
+			name = top
+		}
+	}
+	return ""
+}
+
+// split splits a fully qualified function name into its package path and the remainder, e.g.,
+// "github.com/a/b.TestX.func1" into "github.com/a/b" and "TestX.func1".
+func split(function string) (string, string) {
+	i := strings.LastIndex(function, "/")
+	j := strings.Index(function[i+1:], ".")
+	if j < 0 {
+		return function, ""
+	}
+	return function[:i+1+j], function[i+1+j+1:]
+}
+
+// isTestName returns true iff name is the name of a Test, Benchmark, or Fuzz function.
+func isTestName(name string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+//====================================================================================================//
@@ -0,0 +1,58 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+package testname
+
+//====================================================================================================//
+
+import (
+	"testing"
+)
+
+//====================================================================================================//
+
+func TestFromStack(t *testing.T) {
+	tests := []struct {
+		functions []string
+		expected  string
+	}{
+		{
+			[]string{"github.com/a/b.f", "github.com/a/b.TestX", "testing.tRunner", "runtime.goexit"},
+			"TestX",
+		},
+		{[]string{"github.com/a/b.TestX.func1.2", "testing.tRunner"}, "TestX"},
+		{[]string{"b.BenchmarkY", "testing.(*B).runN", "testing.(*B).run1.func1"}, "BenchmarkY"},
+		{
+			[]string{
+				"b.FuzzZ.func1",
+				"reflect.Value.call",
+				"testing.(*F).Fuzz.func1.1",
+				"testing.tRunner",
+			},
+			"FuzzZ",
+		},
+		{[]string{"b.Example", "testing.runExample", "main.main"}, ""},
+		{[]string{"b.TestX", "main.main"}, ""},
+		{[]string{"b.(*T).TestX", "testing.tRunner"}, ""},
+	}
+	for _, test := range tests {
+		if name := FromStack(test.functions); name != test.expected {
+			t.Errorf("FromStack(%v) = %q, expected %q", test.functions, name, test.expected)
+		}
+	}
+}
+
+//====================================================================================================//
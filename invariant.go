@@ -0,0 +1,97 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// This file contains OnEdge's support for invariants.  Unlike most of OnEdge, invariants are checked
+// in both the "race" and "no-race" versions of OnEdge.  An invariant that holds on entry to a wrapped
+// function, but fails once a panic in that function has been recovered, indicates a global state change
+// that the wrapped function failed to undo.
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+)
+
+//====================================================================================================//
+
+// invariantT is an invariant registered with RegisterInvariant.
+type invariantT struct {
+	name  string
+	check func() error
+}
+
+// invariants contains the invariants registered with RegisterInvariant.
+var invariants []invariantT
+
+//====================================================================================================//
+
+// RegisterInvariant registers an invariant.  check should return nil if the invariant holds, and a
+// non-nil error describing the violation otherwise.  All registered invariants are checked on entry to
+// WrapFunc/WrapFuncR, and again when WrapRecover receives a non-nil recover result.  Any invariant that
+// held on entry, but fails after the recover, is reported.
+func RegisterInvariant(name string, check func() error) {
+	invariants = append(invariants, invariantT{name: name, check: check})
+}
+
+//====================================================================================================//
+
+// checkInvariants checks each registered invariant and returns the results in registration order.
+// checkInvariants returns nil if no invariants are registered.
+func checkInvariants() []error {
+	if len(invariants) <= 0 {
+		return nil
+	}
+	errs := make([]error, len(invariants))
+	for i, invariant := range invariants {
+		errs[i] = checkInvariant(invariant)
+	}
+	return errs
+}
+
+// checkInvariant calls the invariant's check function, converting a panic into an error.
+func checkInvariant(invariant invariantT) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("check panicked: %v", r)
+		}
+	}()
+	return invariant.check()
+}
+
+//====================================================================================================//
+
+// reportBrokenInvariants checks each registered invariant, and reports those that fail now but held
+// according to atEntry.  Invariants registered after atEntry was computed are not reported.
+func reportBrokenInvariants(atEntry []error) {
+	if len(invariants) <= 0 {
+		return
+	}
+	for i, err := range checkInvariants() {
+		if i >= len(atEntry) || atEntry[i] != nil || err == nil {
+			continue
+		}
+		report(
+			FindingBrokenInvariant,
+			"Invariant %q held on WrapFunc entry but fails after recover: %v",
+			invariants[i].name,
+			err,
+		)
+	}
+}
+
+//====================================================================================================//
@@ -0,0 +1,91 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build !race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+//====================================================================================================//
+
+// TestInvariantNoRace checks that invariants are reported by the "no-race" version of OnEdge.  Since
+// no data races can be reported, there is no need to run the test in a separate process.
+func TestInvariantNoRace(t *testing.T) {
+	counter := 0
+	invariants = []invariantT{{
+		name: "counter is zero",
+		check: func() error {
+			if counter != 0 {
+				return fmt.Errorf("counter is %d", counter)
+			}
+			return nil
+		},
+	}}
+	defer func() {
+		invariants = nil
+	}()
+	output := captureStderr(t, func() {
+		WrapFunc(func() {
+			defer func() {
+				if r := WrapRecover(recover()); r != nil {
+				}
+			}()
+			counter++
+			panic(fmt.Errorf(""))
+		})
+	})
+	expected := `=== Invariant "counter is zero" held on WrapFunc entry but fails after recover: ` +
+		`counter is 1`
+	if !strings.Contains(output, expected) {
+		t.Fatalf("output does not contain '%v': '%v'", expected, output)
+	}
+	if len(invariantsAtEntryStack) != 0 {
+		t.Fatalf("unexpected invariantsAtEntryStack: %v", invariantsAtEntryStack)
+	}
+}
+
+//====================================================================================================//
+
+func captureStderr(t *testing.T, f func()) string {
+	file, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	stderr := os.Stderr
+	os.Stderr = file
+	defer func() {
+		os.Stderr = stderr
+	}()
+	f()
+	output, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(output)
+}
+
+//====================================================================================================//
@@ -0,0 +1,106 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+//====================================================================================================//
+
+func TestInvariantIncrementPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+	checkOutput(t, output, `Invariant "exampleCounter is even" held on WrapFunc entry but fails after `+
+		`recover: exampleCounter is 1`, true)
+}
+
+func ExampleInvariantIncrementPanicRecover() {
+	RegisterInvariant("exampleCounter is even", exampleCounterIsEven)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		exampleCounter++
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestInvariantIncrementTwicePanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+	checkOutput(t, output, "held on WrapFunc entry", false)
+}
+
+func ExampleInvariantIncrementTwicePanicRecover() {
+	RegisterInvariant("exampleCounter is even", exampleCounterIsEven)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		exampleCounter += 2
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestInvariantLogIncrementPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+	checkOutput(t, output, `Invariant "exampleCounter is even" held on WrapFunc entry but fails after `+
+		`recover: exampleCounter is 1`, true)
+}
+
+// The invariant's report must not cause the race detector to think that the shadow thread and the main
+// thread were synchronized, even though both threads print.
+func ExampleInvariantLogIncrementPanicRecover() {
+	RegisterInvariant("exampleCounter is even", exampleCounterIsEven)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		fmt.Fprintln(os.Stderr, "incrementing exampleCounter")
+		exampleCounter++
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func exampleCounterIsEven() error {
+	if exampleCounter%2 != 0 {
+		return fmt.Errorf("exampleCounter is %d", exampleCounter)
+	}
+	return nil
+}
+
+//====================================================================================================//
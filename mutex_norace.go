@@ -0,0 +1,38 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build !race
+
+// This is the "no-race" version of OnEdge's mutexes.  Given that you are looking at the source code,
+// chances are you want "mutex_race.go".
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"sync"
+)
+
+//====================================================================================================//
+
+// Mutex is just a sync.Mutex.
+type Mutex = sync.Mutex
+
+// RWMutex is just a sync.RWMutex.
+type RWMutex = sync.RWMutex
+
+//====================================================================================================//
@@ -0,0 +1,310 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+// This is the "race" version of OnEdge's mutexes.  Compare this version to "mutex_norace.go", in which
+// Mutex and RWMutex are simply aliases for their counterparts in the sync package.
+
+// A panic between a call to Lock and the corresponding call to Unlock (that is not deferred) leaves the
+// lock held after the panic is recovered.  This is not a data race, and so Go's race detector cannot
+// see it.  The Mutex and RWMutex types below record their holders.  When WrapRecover receives a panic,
+// any lock that was acquired by the main thread after the enclosing most WrapFuncR was called, and that
+// is still held, is reported along with the stack that acquired it.
+
+// Within a shadow thread, locking and unlocking these types does nothing.  Were the shadow thread to
+// acquire a lock that the main thread left held, it would deadlock.  Moreover, were the shadow thread
+// to acquire a lock that the main thread had released, the race detector would think that the two
+// threads were synchronized, and global state changes made under the lock would not be reported.
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//====================================================================================================//
+
+// lockHolderT records the acquisition of a Mutex or RWMutex.
+type lockHolderT struct {
+	// method is the name of the method that acquired the lock, e.g., "Lock" or "RLock".
+	method string
+	// seq is the value of lockSeq at the time the lock was acquired.
+	seq uint64
+	// callers is the acquiring thread's callers at the time the lock was acquired.
+	callers []uintptr
+	// goroutineID is the id of the acquiring goroutine.  It is set only for readers of an RWMutex.
+	goroutineID int
+}
+
+// lockSeq is incremented each time a Mutex or RWMutex is acquired.
+var lockSeq uint64
+
+// heldLocks contains a lockHolderT for each Mutex or RWMutex that is currently held.
+var heldLocks = make(map[*lockHolderT]struct{})
+
+// heldLocksMutex protects heldLocks.  Mutex and RWMutex can be used by threads other than the main
+// thread.
+var heldLocksMutex sync.Mutex
+
+// shadowThreadName is the name of the function executed by each shadow thread.
+var shadowThreadName = runtime.FuncForPC(reflect.ValueOf(shadowThread).Pointer()).Name()
+
+//====================================================================================================//
+
+// Mutex is a drop-in replacement for sync.Mutex that records its holder.
+type Mutex struct {
+	mutex  sync.Mutex
+	holder *lockHolderT
+}
+
+// Lock locks m.
+func (m *Mutex) Lock() {
+	if inShadowThread() {
+		return
+	}
+	m.mutex.Lock()
+	m.holder = acquireLock("Lock")
+}
+
+// TryLock tries to lock m and reports whether it succeeded.
+func (m *Mutex) TryLock() bool {
+	if inShadowThread() {
+		return true
+	}
+	if !m.mutex.TryLock() {
+		return false
+	}
+	m.holder = acquireLock("TryLock")
+	return true
+}
+
+// Unlock unlocks m.
+func (m *Mutex) Unlock() {
+	if inShadowThread() {
+		return
+	}
+	releaseLock(m.holder)
+	m.holder = nil
+	m.mutex.Unlock()
+}
+
+//====================================================================================================//
+
+// RWMutex is a drop-in replacement for sync.RWMutex that records its holders.
+type RWMutex struct {
+	rwMutex sync.RWMutex
+	writer  *lockHolderT
+	// readersMutex protects readers, which can be modified by multiple threads at once.
+	readersMutex sync.Mutex
+	readers      []*lockHolderT
+}
+
+// Lock locks rw for writing.
+func (rw *RWMutex) Lock() {
+	if inShadowThread() {
+		return
+	}
+	rw.rwMutex.Lock()
+	rw.writer = acquireLock("Lock")
+}
+
+// TryLock tries to lock rw for writing and reports whether it succeeded.
+func (rw *RWMutex) TryLock() bool {
+	if inShadowThread() {
+		return true
+	}
+	if !rw.rwMutex.TryLock() {
+		return false
+	}
+	rw.writer = acquireLock("TryLock")
+	return true
+}
+
+// Unlock unlocks rw for writing.
+func (rw *RWMutex) Unlock() {
+	if inShadowThread() {
+		return
+	}
+	releaseLock(rw.writer)
+	rw.writer = nil
+	rw.rwMutex.Unlock()
+}
+
+// RLock locks rw for reading.
+func (rw *RWMutex) RLock() {
+	if inShadowThread() {
+		return
+	}
+	rw.rwMutex.RLock()
+	rw.addReader(acquireLock("RLock"))
+}
+
+// TryRLock tries to lock rw for reading and reports whether it succeeded.
+func (rw *RWMutex) TryRLock() bool {
+	if inShadowThread() {
+		return true
+	}
+	if !rw.rwMutex.TryRLock() {
+		return false
+	}
+	rw.addReader(acquireLock("TryRLock"))
+	return true
+}
+
+// RUnlock undoes a single RLock call.  The most recently recorded reader acquired by the calling
+// goroutine is forgotten.  If there is no such reader (a read lock can be released by a goroutine other
+// than the one that acquired it), then the most recently recorded reader is forgotten.
+func (rw *RWMutex) RUnlock() {
+	if inShadowThread() {
+		return
+	}
+	goroutineID := currentGoroutineID()
+	rw.readersMutex.Lock()
+	if i := rw.findReader(goroutineID); i >= 0 {
+		releaseLock(rw.readers[i])
+		rw.readers = append(rw.readers[:i], rw.readers[i+1:]...)
+	}
+	rw.readersMutex.Unlock()
+	rw.rwMutex.RUnlock()
+}
+
+// RLocker returns a sync.Locker that implements Lock and Unlock by calling rw.RLock and rw.RUnlock.
+func (rw *RWMutex) RLocker() sync.Locker {
+	return (*rLockerT)(rw)
+}
+
+// addReader records holder as a reader of rw, acquired by the calling goroutine.
+func (rw *RWMutex) addReader(holder *lockHolderT) {
+	holder.goroutineID = currentGoroutineID()
+	rw.readersMutex.Lock()
+	rw.readers = append(rw.readers, holder)
+	rw.readersMutex.Unlock()
+}
+
+// findReader returns the index within readers of the most recently recorded reader acquired by the
+// goroutine with the given id.  If there is no such reader, findReader returns the index of the most
+// recently recorded reader, or -1 if there are no readers.  readersMutex must be held.
+func (rw *RWMutex) findReader(goroutineID int) int {
+	for i := len(rw.readers) - 1; i >= 0; i-- {
+		if rw.readers[i].goroutineID == goroutineID {
+			return i
+		}
+	}
+	return len(rw.readers) - 1
+}
+
+// rLockerT is the type returned by RWMutex.RLocker.
+type rLockerT RWMutex
+
+func (r *rLockerT) Lock()   { (*RWMutex)(r).RLock() }
+func (r *rLockerT) Unlock() { (*RWMutex)(r).RUnlock() }
+
+//====================================================================================================//
+
+// acquireLock creates and records a lockHolderT.  method is the name of the method that acquired the
+// lock.
+func acquireLock(method string) *lockHolderT {
+	holder := &lockHolderT{
+		method:  method,
+		seq:     atomic.AddUint64(&lockSeq, 1),
+		callers: callers(),
+	}
+	heldLocksMutex.Lock()
+	heldLocks[holder] = struct{}{}
+	heldLocksMutex.Unlock()
+	return holder
+}
+
+// releaseLock forgets holder.  holder may be nil, e.g., if the lock was acquired in a shadow thread.
+func releaseLock(holder *lockHolderT) {
+	if holder == nil {
+		return
+	}
+	heldLocksMutex.Lock()
+	delete(heldLocks, holder)
+	heldLocksMutex.Unlock()
+}
+
+//====================================================================================================//
+
+// reportHeldLocks reports each lock that is still held and that was acquired by the main thread after
+// the wrappedFuncT's WrapFuncR was called.
+func reportHeldLocks(wrappedFunc wrappedFuncT) {
+	var holders []*lockHolderT
+	heldLocksMutex.Lock()
+	for holder := range heldLocks {
+		if holder.seq > wrappedFunc.lockSeqAtEntry && hasSuffix(holder.callers, wrappedFunc.callers) {
+			holders = append(holders, holder)
+		}
+	}
+	heldLocksMutex.Unlock()
+	sort.Slice(holders, func(i, j int) bool {
+		return holders[i].seq < holders[j].seq
+	})
+	for _, holder := range holders {
+		report(
+			FindingHeldLock,
+			"Lock acquired after WrapFunc entry is still held after recover (%s):\n%s",
+			holder.method,
+			formatCallers(holder.callers),
+		)
+	}
+}
+
+//====================================================================================================//
+
+// inShadowThread returns true iff the calling function is running in a shadow thread.
+func inShadowThread() bool {
+	frames := runtime.CallersFrames(callers())
+	for {
+		frame, more := frames.Next()
+		if frame.Function == shadowThreadName ||
+			strings.HasPrefix(frame.Function, shadowThreadName+".") {
+			return true
+		}
+		if !more {
+			return false
+		}
+	}
+}
+
+//====================================================================================================//
+
+// formatCallers formats pc in the style of a Go stack trace, with each line indented.
+func formatCallers(pc []uintptr) string {
+	var builder strings.Builder
+	frames := runtime.CallersFrames(pc)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			fmt.Fprintf(&builder, "    %s()\n        %s:%d\n", frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			return builder.String()
+		}
+	}
+}
+
+//====================================================================================================//
@@ -0,0 +1,175 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+//====================================================================================================//
+
+// Global mutexes for tests to lock.
+var (
+	exampleMutex   Mutex
+	exampleRWMutex RWMutex
+)
+
+//====================================================================================================//
+
+func TestMutexLockPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "Lock acquired after WrapFunc entry is still held after recover (Lock)",
+		true)
+	checkOutput(t, output, "ExampleMutexLockPanicRecover.func1()", true)
+}
+
+func ExampleMutexLockPanicRecover() {
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		exampleMutex.Lock()
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestMutexLockDeferUnlockPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "still held after recover", false)
+}
+
+func ExampleMutexLockDeferUnlockPanicRecover() {
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		exampleMutex.Lock()
+		defer exampleMutex.Unlock()
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestMutexLockIncrementPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+	checkOutput(t, output, "still held after recover", false)
+}
+
+func ExampleMutexLockIncrementPanicRecover() {
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		exampleMutex.Lock()
+		exampleCounter++
+		exampleMutex.Unlock()
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestMutexLockBeforeWrapFuncPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "still held after recover", false)
+}
+
+func ExampleMutexLockBeforeWrapFuncPanicRecover() {
+	exampleMutex.Lock()
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		panic(fmt.Errorf(""))
+	})
+	exampleMutex.Unlock()
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestMutexRLockPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "Lock acquired after WrapFunc entry is still held after recover (RLock)",
+		true)
+}
+
+func ExampleMutexRLockPanicRecover() {
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		exampleRWMutex.RLock()
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestMutexRLockOtherReaderRUnlockPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "Lock acquired after WrapFunc entry is still held", false)
+}
+
+// The main thread's RUnlock must forget the main thread's reader, not the other goroutine's, even
+// though the other goroutine's reader was recorded more recently.
+func ExampleMutexRLockOtherReaderRUnlockPanicRecover() {
+	var rw RWMutex
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		rw.RLock()
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			rw.RLock()
+			wg.Done()
+		}()
+		wg.Wait()
+		rw.RUnlock()
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
@@ -19,6 +19,9 @@
 // This is the "no-race" version of OnEdge.  This version does essentially nothing.  Given that you are
 // looking at the source code, chances are you want "onedge_race.go".
 
+// The one exception is invariants (see "invariant.go"), which are checked in this version too.  When no
+// invariants are registered, this version does nothing at all.
+
 //====================================================================================================//
 
 package onedge
@@ -31,22 +34,68 @@ import "C"
 
 //====================================================================================================//
 
-// WrapFunc just calls its function argument f.
+// invariantsAtEntryStack contains the results of checking the registered invariants for each call to
+// WrapFuncR on the stack.  It is used only when invariants are registered.
+var invariantsAtEntryStack [][]error
+
+//====================================================================================================//
+
+// Track does nothing.
+func Track(name string, ptr interface{}) {
+}
+
+//====================================================================================================//
+
+// WrapFunc just calls its function argument f.  If invariants are registered, f is called via
+// WrapFuncR so that they are checked.
 func WrapFunc(f func()) {
+	if len(invariants) > 0 {
+		WrapFuncR(func() interface{} {
+			f()
+			return nil
+		})
+		return
+	}
 	f()
 }
 
+// WrapFuncOpts is like WrapFunc.  Options apply only when Go's race detector is enabled.
+func WrapFuncOpts(opts Options, f func()) {
+	WrapFunc(f)
+}
+
+// WrapFuncArgs is like WrapFunc.  args are used only when Go's race detector is enabled.
+func WrapFuncArgs(f func(), args ...interface{}) {
+	WrapFunc(f)
+}
+
 //====================================================================================================//
 
-// WrapFuncR just calls its function argument f and returns the result.
+// WrapFuncR just calls its function argument f and returns the result.  If invariants are registered,
+// they are checked first.
 func WrapFuncR(f func() interface{}) interface{} {
+	if len(invariants) > 0 {
+		invariantsAtEntryStack = append(invariantsAtEntryStack, checkInvariants())
+		defer func() {
+			invariantsAtEntryStack = invariantsAtEntryStack[:len(invariantsAtEntryStack)-1]
+		}()
+	}
 	return f()
 }
 
+// WrapFuncROpts is like WrapFuncR.  Options apply only when Go's race detector is enabled.
+func WrapFuncROpts(opts Options, f func() interface{}) interface{} {
+	return WrapFuncR(f)
+}
+
 //====================================================================================================//
 
-// WrapRecover just returns its argument r.
+// WrapRecover just returns its argument r.  If r is non-nil, then any invariants that held when the
+// enclosing most WrapFuncR was called, but that no longer hold, are reported.
 func WrapRecover(r interface{}) interface{} {
+	if r != nil && len(invariantsAtEntryStack) > 0 {
+		reportBrokenInvariants(invariantsAtEntryStack[len(invariantsAtEntryStack)-1])
+	}
 	return r
 }
 
@@ -34,9 +34,15 @@
 package onedge
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"reflect"
 	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 //====================================================================================================//
@@ -75,12 +81,68 @@ type wrappedFuncT struct {
 	// by the main thread to distinguish itself from shadow threads and vice versa (see haveCallers
 	// below).
 	callers []uintptr
+	// site is the location of the call to WrapFuncR (see "trace_race.go").  ctx and task are the call's
+	// trace context and task.
+	site Site
+	ctx  context.Context
+	task *trace.Task
 	// f is WrapFuncR's function argument.
 	f func() interface{}
-	// toShadowThreadCallFuncChan is used to tell the corresponding shadow thread to call f.
-	toShadowThreadCallFuncChan chan struct{}
-	// fromShadowThreadCallFuncChan is used to tell the main thread that a call to f is complete.
-	fromShadowThreadCallFuncChan chan struct{}
+	// checks contains the optional checks (see "checks.go") that are enabled for this call.
+	checks Checks
+	// args are the values in Options.Args, and argsAtEntry is a snapshot of them taken when WrapFuncR
+	// was called.
+	args        []interface{}
+	argsAtEntry snapshotT
+	// trackedAtEntry is a snapshot of the tracked globals (see Track below) taken when WrapFuncR was
+	// called.
+	trackedAtEntry snapshotT
+	// invariantsAtEntry are the results of checking the registered invariants (see RegisterInvariant)
+	// when WrapFuncR was called.
+	invariantsAtEntry []error
+	// processStateAtEntry is a snapshot of the process state (see "processstate_race.go") taken when
+	// WrapFuncR was called.  It is set only if CheckProcessState is enabled.
+	processStateAtEntry snapshotT
+	// lockSeqAtEntry is the value of lockSeq (see "mutex_race.go") when WrapFuncR was called.
+	lockSeqAtEntry uint64
+	// goroutineID is the id of the main thread's goroutine.  goroutinesAtEntry contains the ids of all
+	// goroutines that existed when WrapFuncR was called.  Both are set only if CheckGoroutines is
+	// enabled.
+	goroutineID       int
+	goroutinesAtEntry map[int]bool
+	// fdsAtEntry contains the file descriptors that were open when WrapFuncR was called, and
+	// fdsAtRecover contains those that were open when WrapRecover received a panic.  Both are set only
+	// if CheckFileDescriptors is enabled.
+	fdsAtEntry   map[int]bool
+	fdsAtRecover map[int]bool
+	// effectsAtEntry is the length of mainThreadEffects (see "effect_race.go") when WrapFuncR was
+	// called.
+	effectsAtEntry int
+	// checkpoints is the number of calls to Checkpoint (see "checkpoint_race.go") made by f.
+	// checkpointSite is the location of the last such call, and effectsAtCheckpoint is the length
+	// of mainThreadEffects at the time of that call.
+	checkpoints         int
+	checkpointSite      Site
+	effectsAtCheckpoint int
+	// recovered is set by WrapRecover when it receives a panic, and recoverSite is the location of the
+	// call to WrapRecover that received it.
+	recovered   bool
+	recoverSite Site
+	// holdingFindings is set while the findings reported for this call are to be held, rather than
+	// passed to the Reporter, and heldFindings contains those findings (see holdFindings).
+	holdingFindings bool
+	heldFindings    []Finding
+	// shadowResult is the shadow thread's result, which WrapRecover sets.  compareResults is set when
+	// shadowResult should be compared to the main thread's result.
+	shadowResult   interface{}
+	compareResults bool
+	// toShadowThreadCallFuncChan is used to tell the corresponding shadow thread to call f, and to pass
+	// checkpoints to the shadow thread.
+	toShadowThreadCallFuncChan chan int
+	// fromShadowThreadCallFuncChan is used to tell the main thread that a call to f is complete, and to
+	// pass f's result (nil if f panicked, or a shadowPanicT if f panicked and did not recover) to the
+	// main thread.
+	fromShadowThreadCallFuncChan chan interface{}
 	// fromShadowThreadRecoverChan is used to pass the result of a recover to the main thread.
 	fromShadowThreadRecoverChan chan interface{}
 	// toShadowThreadRecoverChan is used by the main thread to acknowledge receipt of a recover result.
@@ -99,19 +161,66 @@ var mainThreadStack []wrappedFuncT
 // pushed onto the stack".
 var shadowThreadWrapFuncDepth = 0
 
+// shadowThreadRuns is the number of times that a shadow thread has called its function argument (see
+// ShadowRun).
+var shadowThreadRuns uint64 = 0
+
+// trackedGlobals contains the globals registered with Track.
+var trackedGlobals []trackedGlobalT
+
+// trackedGlobalT is a global registered with Track.
+type trackedGlobalT struct {
+	name string
+	ptr  interface{}
+}
+
+//====================================================================================================//
+
+// Track registers the global pointed to by ptr under the given name.  When a shadow thread fails to
+// panic as it should have, OnEdge reports which tracked globals changed between the call to WrapFuncR
+// and the start of the shadow thread.  Such changes are likely what caused the shadow thread to behave
+// differently from the main thread.
+func Track(name string, ptr interface{}) {
+	if v := reflect.ValueOf(ptr); v.Kind() != reflect.Ptr || v.IsNil() {
+		panic(fmt.Sprintf("onedge.Track: %s: expected a non-nil pointer, got %T", name, ptr))
+	}
+	trackedGlobals = append(trackedGlobals, trackedGlobalT{name: name, ptr: ptr})
+}
+
 //====================================================================================================//
 
 // WrapFunc is like WrapFuncR (below), but its function argument f does not return a result.
 func WrapFunc(f func()) {
-	WrapFuncR(func() interface{} {
+	WrapFuncROpts(Options{}, func() interface{} {
+		f()
+		return nil
+	})
+}
+
+// WrapFuncOpts is like WrapFuncROpts (below), but its function argument f does not return a result.
+func WrapFuncOpts(opts Options, f func()) {
+	WrapFuncROpts(opts, func() interface{} {
 		f()
 		return nil
 	})
 }
 
+// WrapFuncArgs is like WrapFunc, but args are snapshotted on entry, and any changes to them are
+// reported when a panic is recovered.  args should be the pointers, slices, maps, etc. that f captures
+// and that are shared with the caller.  WrapFuncArgs(f, args...) is equivalent to
+// WrapFuncOpts(Options{Args: args}, f).
+func WrapFuncArgs(f func(), args ...interface{}) {
+	WrapFuncOpts(Options{Args: args}, f)
+}
+
+// WrapFuncR is like WrapFuncROpts (below), but uses the default options.
+func WrapFuncR(f func() interface{}) interface{} {
+	return WrapFuncROpts(Options{}, f)
+}
+
 //====================================================================================================//
 
-// WrapFuncR is perhaps best explained using pseudocode.
+// WrapFuncROpts is perhaps best explained using pseudocode.
 //   if in a shadow thread:
 //     increment shadowThreadWrapFuncDepth
 //     call the function f
@@ -119,17 +228,24 @@ func WrapFunc(f func()) {
 //   else (i.e., in the main thread):
 //     create channels for communicating with a shadow thread and record them in a wrappedFuncT
 //     push the wrappedFuncT onto the stack
+//     record the call in the site's statistics (see "stats.go")
 //     create a new shadow thread
-//     call the function f
+//     invoke the OnEnter hook (see "hooks.go")
+//     call the function f (see "trace_race.go" for the trace task and regions created)
+//     if a panic was recovered, compare f's result to the shadow thread's
+//     if no panic was recovered and idempotence checking is enabled, tell the shadow thread to call f
+//       again, and compare the results
+//     if a panic was recovered, perform any enabled optional checks that apply at this point
 //     tell the shadow thread to exit
+//     invoke the OnExit hook
 //     pop the wrappedFuncT
 //   either way, finally:
 //     return the result of calling f
-// Note that the main thread must create the shadow thread here, in WrapFuncR, and not in WrapRecover.
-// If the main thread were to create the shadow thread in WrapRecover, then any global state changes
-// caused by executing f in the main thread would have occurred prior to the shadow thread's creation.
-// Thus, those global state changes would not be eligible to be data races.
-func WrapFuncR(f func() interface{}) interface{} {
+// Note that the main thread must create the shadow thread here, in WrapFuncROpts, and not in
+// WrapRecover.  If the main thread were to create the shadow thread in WrapRecover, then any global
+// state changes caused by executing f in the main thread would have occurred prior to the shadow
+// thread's creation.  Thus, those global state changes would not be eligible to be data races.
+func WrapFuncROpts(opts Options, f func() interface{}) interface{} {
 	inMainThread := len(mainThreadStack) <= 0 ||
 		haveCallers(mainThreadStack[len(mainThreadStack)-1].callers)
 	if !inMainThread {
@@ -142,52 +258,127 @@ func WrapFuncR(f func() interface{}) interface{} {
 		wrappedFunc := wrappedFuncT{
 			callers:                      callers(),
 			f:                            f,
-			toShadowThreadCallFuncChan:   make(chan struct{}),
-			fromShadowThreadCallFuncChan: make(chan struct{}),
+			checks:                       enabledChecks | opts.Checks,
+			args:                         opts.Args,
+			argsAtEntry:                  snapshotArgs(opts.Args),
+			trackedAtEntry:               snapshotTracked(),
+			invariantsAtEntry:            checkInvariants(),
+			lockSeqAtEntry:               atomic.LoadUint64(&lockSeq),
+			effectsAtEntry:               len(mainThreadEffects),
+			toShadowThreadCallFuncChan:   make(chan int),
+			fromShadowThreadCallFuncChan: make(chan interface{}),
 			fromShadowThreadRecoverChan:  make(chan interface{}),
 			toShadowThreadRecoverChan:    make(chan struct{}),
 		}
+		wrappedFunc.site = callSite(wrappedFunc.callers)
+		wrappedFunc.ctx, wrappedFunc.task = startTask(wrappedFunc.site)
+		if wrappedFunc.checks&CheckGoroutines != 0 {
+			wrappedFunc.goroutineID = currentGoroutineID()
+			wrappedFunc.goroutinesAtEntry = goroutineIDs()
+		}
+		if wrappedFunc.checks&CheckFileDescriptors != 0 {
+			wrappedFunc.fdsAtEntry = openFDs()
+		}
+		if wrappedFunc.checks&CheckProcessState != 0 {
+			wrappedFunc.processStateAtEntry = snapshotProcessState()
+		}
 		mainThreadStack = append(mainThreadStack, wrappedFunc)
+		recordCall(wrappedFunc.site, len(mainThreadStack))
 		go shadowThread(toShadowThreadExitChan, wrappedFunc)
 		defer mainThreadWrapFuncRFinal(toShadowThreadExitChan)
+		invokeOnEnter(wrappedFunc.site)
+		var result interface{}
+		defer setProfLabel(getProfLabel())
+		pprof.Do(wrappedFunc.ctx, profileLabels(wrappedFunc.site, "main"), func(ctx context.Context) {
+			defer trace.StartRegion(ctx, "onedge.main").End()
+			result = f()
+		})
+		checkResult(result)
+		return result
 	}
 	return f()
 }
 
+// checkResult is called by the main thread when the enclosing most WrapFuncROpts's function argument
+// returns result.  If a panic was recovered, and the shadow thread recovered a similar panic, then
+// result is compared to the shadow thread's result.  If no panic was recovered and idempotence
+// checking is enabled, then the function argument is re-executed (see "idempotence_race.go").
+func checkResult(result interface{}) {
+	wrappedFunc := mainThreadStack[len(mainThreadStack)-1]
+	if wrappedFunc.compareResults && !comparator(result, wrappedFunc.shadowResult) {
+		report(
+			FindingDifferentResult,
+			"Shadow thread returned different result: %v != %v",
+			result,
+			wrappedFunc.shadowResult,
+		)
+	}
+	if !wrappedFunc.recovered && wrappedFunc.checks&CheckIdempotence != 0 {
+		checkIdempotence(wrappedFunc, result)
+	}
+}
+
 // sam.moelius: OnEdge reports a data race between the calculation of inMainThread in the first line of
-// WrapFuncR, and the popping of mainThreadStack.  Suppressing all reports associated with WrapFuncR
-// would be too much.  An alternative is to put the calculation of inMainThread or the popping of
-// mainThreadStack into its own function, and to suppress reports associated with that function.  I
-// chose the latter.
+// WrapFuncROpts, and the popping of mainThreadStack.  Suppressing all reports associated with
+// WrapFuncROpts would be too much.  An alternative is to put the calculation of inMainThread or the
+// popping of mainThreadStack into its own function, and to suppress reports associated with that
+// function.  I chose the latter.
 //   Note that there is no similar data race between the increment and decrement of
 // shadowThreadWrapFuncDepth.  That is because (as mentioned above) shadow threads do not create other
 // shadow threads.
 //   OnEdge similarly reports a data race between the calculation of inMainThread in the first line of
-// WrapFuncR, and the acquisition of mainThreadStack's top element in WrapRecover.  But, in that case,
-// there is no problem with suppressing all reports associated with WrapRecover.
+// WrapFuncROpts, and the acquisition of mainThreadStack's top element in WrapRecover.  But, in that
+// case, there is no problem with suppressing all reports associated with WrapRecover.
 func mainThreadWrapFuncRFinal(toShadowThreadExitChan chan struct{}) {
+	wrappedFunc := mainThreadStack[len(mainThreadStack)-1]
+	if wrappedFunc.recovered && wrappedFunc.checks&CheckGoroutines != 0 {
+		reportLeakedGoroutines(wrappedFunc)
+	}
+	if wrappedFunc.recovered && wrappedFunc.checks&CheckFileDescriptors != 0 {
+		reportLeakedFDs(wrappedFunc)
+	}
 	toShadowThreadExitChan <- struct{}{}
+	wrappedFunc.task.End()
+	invokeOnExit(wrappedFunc.site)
 	mainThreadStack = mainThreadStack[:len(mainThreadStack)-1]
+	if len(mainThreadStack) <= 0 {
+		mainThreadEffects = nil
+	}
 }
 
 //====================================================================================================//
 
-// WrapRecover, like WrapFuncR, is perhaps best explained using pseudocode.
+// WrapRecover, like WrapFuncROpts, is perhaps best explained using pseudocode.
 //   if in a shadow thread:
 //     if the enclosing most WrapFuncR was called in the main thread:
 //       forward argument r (the recover result) to the main thread
+//       if r indicates that the shadow thread reached the main thread's last checkpoint, re-panic
 //   else (i.e., in the main thread):
 //     if r is non-nil (i.e., a panic occurred):
+//       disable the race detector's handling of synchronization, and hold any findings reported
+//       record the location of the call to WrapRecover
+//       invoke the OnPanic hook (see "hooks.go")
+//       report the last checkpoint reached, if any
+//       report any invariants that held when WrapFuncR was called, but that no longer hold
+//       report any changes to the arguments in Options.Args since WrapFuncR was called
+//       report any locks acquired since WrapFuncR was called that are still held
+//       report any external effects recorded since WrapFuncR was called
+//       perform any enabled optional checks that apply at this point
+//       announce the shadow thread's re-execution, if findings are being printed as JSON
+//       re-enable the race detector's handling of synchronization
 //       tell the shadow thread corresponding to the enclosing most WrapFuncR to call its function
-//         argument
-//       wait for the shadow thread to forward any recover results
+//         argument, and wait for it to do so (see runShadowThread below)
+//       pass the held findings to the Reporter, and record the panic in the site's statistics
 //       generate an error message if no recover results are received from the shadow thread, multiple
 //         results are received, or a result does not match what was obtained in the main thread
+//       if the shadow thread did not panic, explain why using the tracked globals and the shadow
+//         thread's result
+//       record the shadow thread's result, to be compared to the main thread's (see checkResult)
 //   either way, finally:
 //     return r
 func WrapRecover(r interface{}) interface{} {
 	if len(mainThreadStack) <= 0 {
-		fmt.Fprintf(os.Stderr, "=== WrapRecover with no enclosing WrapFunc/WrapFuncR.\n")
+		report(FindingNoEnclosingWrapFunc, "WrapRecover with no enclosing WrapFunc/WrapFuncR.")
 		return r
 	}
 	wrappedFunc := mainThreadStack[len(mainThreadStack)-1]
@@ -195,51 +386,85 @@ func WrapRecover(r interface{}) interface{} {
 		if shadowThreadWrapFuncDepth <= 0 {
 			wrappedFunc.fromShadowThreadRecoverChan <- r
 			<-wrappedFunc.toShadowThreadRecoverChan
+			if _, ok := r.(checkpointReachedT); ok {
+				panic(r)
+			}
 		}
 		return r
 	}
 	if r != nil {
-		// sam.moelius: Disable the race detector while sending to the shadow thread.  This causes
-		// the race detector to think that the main and shadow thread are synchronized only up to the
-		// point at which the shadow thread was created.
+		// Nothing that the main thread does between the panic and the shadow thread's re-execution of
+		// the wrapped function may synchronize with the shadow thread.  Printing a finding, taking a
+		// lock, or calling os.Environ would cause the race detector to think that the two threads were
+		// synchronized, and the global state changes made before the panic would go unreported.  So
+		// the race detector's handling of synchronization is disabled until the shadow thread is told
+		// to re-execute the function, and the findings reported in the meantime are held until the
+		// re-execution is complete.  Reports associated with WrapRecover are suppressed (see init
+		// above), so the main thread's accesses in the meantime are not themselves reported.
 		runtime.RaceDisable()
-		wrappedFunc.toShadowThreadCallFuncChan <- struct{}{}
+		holdFindings()
+		mainThreadStack[len(mainThreadStack)-1].recovered = true
+		mainThreadStack[len(mainThreadStack)-1].recoverSite = callSite(callers())
+		defer trace.StartRegion(wrappedFunc.ctx, "onedge.recover").End()
+		invokeOnPanic(wrappedFunc.site, r)
+		reportCheckpoint(wrappedFunc)
+		reportBrokenInvariants(wrappedFunc.invariantsAtEntry)
+		reportArgChanges(wrappedFunc)
+		reportHeldLocks(wrappedFunc)
+		reportEffects(wrappedFunc)
+		if wrappedFunc.checks&CheckFileDescriptors != 0 {
+			mainThreadStack[len(mainThreadStack)-1].fdsAtRecover = openFDs()
+		}
+		if wrappedFunc.checks&CheckProcessState != 0 {
+			reportProcessStateChanges(wrappedFunc)
+		}
+		trackedAtShadowStart := snapshotTracked()
+		announceShadow(wrappedFunc.site, mainThreadStack[len(mainThreadStack)-1].recoverSite)
 		runtime.RaceEnable()
-		nRecover := 0
-		for {
-			var exit bool
-			var shadowR interface{}
-			select {
-			case <-wrappedFunc.fromShadowThreadCallFuncChan:
-				exit = true
-				break
-			case shadowR = <-wrappedFunc.fromShadowThreadRecoverChan:
-				break
-			}
-			if exit {
-				break
-			}
+		shadowRs, shadowResult := runShadowThread(wrappedFunc, wrappedFunc.checkpoints)
+		releaseFindings()
+		recordPanic(wrappedFunc.site)
+		didNotPanic := false
+		differentPanic := false
+		for _, shadowR := range shadowRs {
 			if shadowR == nil {
-				fmt.Fprintf(os.Stderr, "=== Shadow thread did not panic as it should have.\n")
-			} else {
-				s := fmt.Sprintf("%v", r)
-				shadowS := fmt.Sprintf("%v", shadowR)
-				if s != shadowS {
-					fmt.Fprintf(
-						os.Stderr,
-						"=== Shadow thread panicked with different argument: %s != %s\n",
-						s,
-						shadowS,
-					)
-				}
+				didNotPanic = true
+			} else if _, ok := shadowR.(checkpointReachedT); !ok && !comparator(r, shadowR) {
+				differentPanic = true
+				report(
+					FindingDifferentPanic,
+					"Shadow thread panicked with different argument: %v != %v",
+					r,
+					shadowR,
+				)
 			}
-			nRecover++
-			wrappedFunc.toShadowThreadRecoverChan <- struct{}{}
 		}
-		if nRecover <= 0 {
-			fmt.Fprintf(os.Stderr, "=== Shadow thread did not recover as it should have.\n")
-		} else if nRecover >= 2 {
-			fmt.Fprintf(os.Stderr, "=== Shadow thread recovered multiple times (%d).\n", nRecover)
+		// The main thread's result is not known until f returns.  So the comparison of the results is
+		// made by checkResult.
+		if len(shadowRs) == 1 && shadowRs[0] != nil {
+			if _, ok := shadowRs[0].(checkpointReachedT); !ok {
+				mainThreadStack[len(mainThreadStack)-1].shadowResult = shadowResult
+				mainThreadStack[len(mainThreadStack)-1].compareResults = true
+			}
+		}
+		if didNotPanic {
+			report(
+				FindingDidNotPanic,
+				"Shadow thread did not panic as it should have.\n%s",
+				explainDidNotPanic(wrappedFunc.trackedAtEntry, trackedAtShadowStart, shadowResult),
+			)
+		}
+		if len(shadowRs) == 1 && !didNotPanic && !differentPanic {
+			recordReproduced(wrappedFunc.site)
+		}
+		if len(shadowRs) <= 0 {
+			report(FindingDidNotRecover, "Shadow thread did not recover as it should have.")
+		} else if len(shadowRs) >= 2 {
+			report(
+				FindingRecoveredMultipleTimes,
+				"Shadow thread recovered multiple times (%d).",
+				len(shadowRs),
+			)
 		}
 	}
 	return r
@@ -247,10 +472,65 @@ func WrapRecover(r interface{}) interface{} {
 
 //====================================================================================================//
 
+// runShadowThread tells the shadow thread corresponding to wrappedFunc to call its function argument,
+// and waits for it to do so.  checkpoints is the number of calls to Checkpoint after which the shadow
+// thread should stop.  runShadowThread returns the recover results forwarded by the shadow thread, and
+// the shadow thread's result (nil if the function panicked).  The time taken is recorded in the site's
+// statistics.  Prior accesses to the variables passed to AllowVar are forgotten both before and after
+// the shadow thread calls its function argument.
+func runShadowThread(wrappedFunc wrappedFuncT, checkpoints int) ([]interface{}, interface{}) {
+	resetAllowedVars()
+	defer resetAllowedVars()
+	start := time.Now()
+	defer func() {
+		recordShadowRun(wrappedFunc.site, time.Since(start))
+	}()
+	// sam.moelius: Disable the race detector while sending to the shadow thread.  This causes the race
+	// detector to think that the main and shadow thread are synchronized only up to the point at which
+	// the shadow thread was created.
+	runtime.RaceDisable()
+	// The OnShadowStart hook is invoked with the race detector disabled too, so that its
+	// synchronization (e.g., printing) does not hide data races (see WrapRecover).
+	invokeOnShadowStart(wrappedFunc.site)
+	wrappedFunc.toShadowThreadCallFuncChan <- checkpoints
+	runtime.RaceEnable()
+	var shadowRs []interface{}
+	for {
+		select {
+		case shadowResult := <-wrappedFunc.fromShadowThreadCallFuncChan:
+			if shadowPanic, ok := shadowResult.(shadowPanicT); ok {
+				report(
+					FindingShadowPanic,
+					"Shadow thread panicked and did not recover: %v",
+					shadowPanic.r,
+				)
+				shadowResult = nil
+			}
+			return shadowRs, shadowResult
+		case shadowR := <-wrappedFunc.fromShadowThreadRecoverChan:
+			invokeOnShadowResult(wrappedFunc.site, shadowR)
+			shadowRs = append(shadowRs, shadowR)
+			wrappedFunc.toShadowThreadRecoverChan <- struct{}{}
+		}
+	}
+}
+
+//====================================================================================================//
+
+// shadowPanicT is sent by a shadow thread in place of its result when its function argument panicked
+// and did not recover.  r is the panic's argument.  The panic is reported by the main thread (see
+// runShadowThread), because a Reporter must not be called by a shadow thread.
+type shadowPanicT struct {
+	r interface{}
+}
+
 // shadowThread is the function executed by each shadow thread.
 func shadowThread(toShadowThreadExitChan chan struct{}, wrappedFunc wrappedFuncT) {
+	labels := profileLabels(wrappedFunc.site, "shadow")
+	pprof.SetGoroutineLabels(pprof.WithLabels(wrappedFunc.ctx, labels))
 	for {
 		var exit bool
+		var checkpoints int
 		// sam.moelius: Disable the race detector while receiving from the main thread.  This causes
 		// the race detector to think that the main and shadow thread are synchronized only up to the
 		// point at which the shadow thread was created.
@@ -259,37 +539,116 @@ func shadowThread(toShadowThreadExitChan chan struct{}, wrappedFunc wrappedFuncT
 		case <-toShadowThreadExitChan:
 			exit = true
 			break
-		case <-wrappedFunc.toShadowThreadCallFuncChan:
+		case checkpoints = <-wrappedFunc.toShadowThreadCallFuncChan:
 			break
 		}
 		runtime.RaceEnable()
 		if exit {
 			break
 		}
+		shadowThreadCheckpoints = 0
+		shadowThreadCheckpointTarget = checkpoints
+		shadowThreadRuns++
 		// sam.moelius: Capture any panics that the shadow thread might generate while executing the
-		// wrapped function.  Allowing those panics to escape would cause the program to terminate.
+		// wrapped function.  Allowing those panics to escape would cause the program to terminate.  A
+		// checkpointReachedT is expected, as WrapRecover re-panics with it.
+		var result interface{}
+		region := trace.StartRegion(wrappedFunc.ctx, "onedge.shadow")
 		func() {
 			defer func() {
 				if r := recover(); r != nil {
-					fmt.Fprintf(os.Stderr, "=== Shadow thread panicked and did not recover: %v\n", r)
+					if _, ok := r.(checkpointReachedT); ok {
+						return
+					}
+					result = shadowPanicT{r}
 				}
 			}()
-			wrappedFunc.f()
+			result = wrappedFunc.f()
 		}()
-		wrappedFunc.fromShadowThreadCallFuncChan <- struct{}{}
+		region.End()
+		wrappedFunc.fromShadowThreadCallFuncChan <- result
 	}
 }
 
 //====================================================================================================//
 
+// explainDidNotPanic describes the tracked globals that differ between trackedAtEntry and
+// trackedAtShadowStart, followed by the shadow thread's result.  It is called after a shadow thread
+// failed to panic as it should have.  Each line of the description is indented.
+func explainDidNotPanic(
+	trackedAtEntry, trackedAtShadowStart snapshotT,
+	shadowResult interface{},
+) string {
+	var builder strings.Builder
+	if len(trackedGlobals) <= 0 {
+		fmt.Fprintf(&builder, "  No globals are tracked (see onedge.Track).\n")
+	} else if diffs := diffSnapshots(trackedAtEntry, trackedAtShadowStart); len(diffs) <= 0 {
+		fmt.Fprintf(&builder, "  No tracked globals changed between WrapFunc entry and shadow start.\n")
+	} else {
+		for _, diff := range diffs {
+			fmt.Fprintf(
+				&builder,
+				"  Tracked global changed between WrapFunc entry and shadow start: %s\n",
+				diff,
+			)
+		}
+	}
+	fmt.Fprintf(&builder, "  Shadow thread's WrapFuncR result: %v", shadowResult)
+	return builder.String()
+}
+
+//====================================================================================================//
+
+// snapshotArgs returns a snapshot of args, the values in Options.Args.
+func snapshotArgs(args []interface{}) snapshotT {
+	if len(args) <= 0 {
+		return nil
+	}
+	snapshot := make(snapshotT)
+	for i, arg := range args {
+		snapshot.add(fmt.Sprintf("args[%d]", i), reflect.ValueOf(arg))
+	}
+	return snapshot
+}
+
+// reportArgChanges reports the changes to the wrappedFuncT's arguments since its WrapFuncR was called.
+func reportArgChanges(wrappedFunc wrappedFuncT) {
+	if len(wrappedFunc.args) <= 0 {
+		return
+	}
+	for _, diff := range diffSnapshots(wrappedFunc.argsAtEntry, snapshotArgs(wrappedFunc.args)) {
+		report(FindingArgumentChanged, "Argument changed between WrapFunc entry and recover: %s", diff)
+	}
+}
+
+//====================================================================================================//
+
+// snapshotTracked returns a snapshot of the globals registered with Track.
+func snapshotTracked() snapshotT {
+	if len(trackedGlobals) <= 0 {
+		return nil
+	}
+	snapshot := make(snapshotT)
+	for _, tracked := range trackedGlobals {
+		snapshot.add(tracked.name, reflect.ValueOf(tracked.ptr).Elem())
+	}
+	return snapshot
+}
+
+//====================================================================================================//
+
 // haveCallers returns true iff pc is a suffix of the calling function's callers.
 func haveCallers(pc []uintptr) bool {
-	thisPC := callers()
-	if len(pc) > len(thisPC) {
+	return hasSuffix(callers(), pc)
+}
+
+// hasSuffix returns true iff suffix is a suffix of pc.
+func hasSuffix(pc []uintptr, suffix []uintptr) bool {
+	if len(suffix) > len(pc) {
 		return false
 	}
-	for i := 0; i < len(pc); i++ {
-		if pc[len(pc)-i-1] != thisPC[len(thisPC)-i-1] {
+	for i := 0; i < len(suffix); i++ {
+		if suffix[len(suffix)-i-1] != pc[len(pc)-i-1] {
 			return false
 		}
 	}
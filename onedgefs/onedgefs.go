@@ -0,0 +1,317 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// Package onedgefs provides a writable filesystem that reports file mutations made before a panic.
+//
+// Creating, writing, renaming, and removing files are global state changes that Go's race detector
+// cannot see.  The filesystem returned by OS records each such mutation using onedge.RecordEffect.  So
+// if such a mutation is made between entry to a function wrapped by onedge.WrapFunc and a panic, then
+// the mutation is reported when the panic is recovered.
+//
+// Within a shadow thread, mutations are redirected to an in-memory overlay, so that the real disk is
+// not touched twice.  (They are not recorded, as onedge.RecordEffect ignores calls made within a
+// shadow thread.)  Reads within a shadow thread see the overlay.  The overlay is discarded at the start
+// of each re-execution of a wrapped function by a shadow thread, and is never written to disk.
+package onedgefs
+
+//====================================================================================================//
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	onedge "github.com/trailofbits/on-edge"
+)
+
+//====================================================================================================//
+
+// effectKind is the kind passed to onedge.RecordEffect.
+const effectKind = "fs"
+
+// writeFlags are the os.OpenFile flags that allow a file to be written.
+const writeFlags = os.O_WRONLY | os.O_RDWR | os.O_APPEND | os.O_CREATE | os.O_TRUNC
+
+//====================================================================================================//
+
+// FS is a writable filesystem.
+type FS interface {
+	// Open opens the named file for reading.
+	Open(name string) (File, error)
+	// Create creates or truncates the named file.
+	Create(name string) (File, error)
+	// OpenFile is the generalized open call; see os.OpenFile.
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	// Rename renames (moves) oldpath to newpath.
+	Rename(oldpath, newpath string) error
+	// Remove removes the named file or (empty) directory.
+	Remove(name string) error
+}
+
+// File is a file opened by an FS.
+type File interface {
+	io.Reader
+	io.Writer
+	io.Closer
+	Name() string
+}
+
+//====================================================================================================//
+
+// OS returns an FS backed by the operating system.
+func OS() FS {
+	return &osFS{overlay: make(map[string]*overlayEntryT)}
+}
+
+type osFS struct {
+	// overlayMutex protects overlay, which is used only by shadow threads.  overlayRun is the
+	// onedge.ShadowRun for which overlay was created.
+	overlayMutex sync.Mutex
+	overlay      map[string]*overlayEntryT
+	overlayRun   uint64
+}
+
+// overlayEntryT is a file within the overlay.  A removed file has removed set to true.
+type overlayEntryT struct {
+	data    []byte
+	removed bool
+}
+
+//====================================================================================================//
+
+func (fs *osFS) Open(name string) (File, error) {
+	return fs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (fs *osFS) Create(name string) (File, error) {
+	return fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (fs *osFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	if flag&os.O_CREATE != 0 {
+		onedge.RecordEffect(effectKind, fmt.Sprintf("create %s", name))
+	} else if flag&os.O_TRUNC != 0 {
+		onedge.RecordEffect(effectKind, fmt.Sprintf("truncate %s", name))
+	}
+	if !onedge.InShadowThread() {
+		file, err := os.OpenFile(name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return &osFile{File: file}, nil
+	}
+	fs.lockOverlay()
+	defer fs.overlayMutex.Unlock()
+	if flag&writeFlags == 0 {
+		// The file is only being read.  So if it is not in the overlay, read it from disk.
+		entry, ok := fs.overlay[key(name)]
+		if !ok {
+			file, err := os.Open(name)
+			if err != nil {
+				return nil, err
+			}
+			return &osFile{File: file, shadow: true}, nil
+		}
+		if entry.removed {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		return &overlayFile{fs: fs, name: name, entry: entry}, nil
+	}
+	entry, err := fs.lookup(name, flag&os.O_CREATE != 0)
+	if err != nil {
+		return nil, err
+	}
+	if flag&os.O_TRUNC != 0 {
+		entry.data = nil
+	}
+	return &overlayFile{fs: fs, name: name, entry: entry, append: flag&os.O_APPEND != 0}, nil
+}
+
+func (fs *osFS) Rename(oldpath, newpath string) error {
+	onedge.RecordEffect(effectKind, fmt.Sprintf("rename %s to %s", oldpath, newpath))
+	if !onedge.InShadowThread() {
+		return os.Rename(oldpath, newpath)
+	}
+	fs.lockOverlay()
+	defer fs.overlayMutex.Unlock()
+	entry, err := fs.lookup(oldpath, false)
+	if err != nil {
+		return err
+	}
+	fs.overlay[key(newpath)] = &overlayEntryT{data: entry.data}
+	fs.overlay[key(oldpath)] = &overlayEntryT{removed: true}
+	return nil
+}
+
+func (fs *osFS) Remove(name string) error {
+	onedge.RecordEffect(effectKind, fmt.Sprintf("remove %s", name))
+	if !onedge.InShadowThread() {
+		return os.Remove(name)
+	}
+	fs.lockOverlay()
+	defer fs.overlayMutex.Unlock()
+	if _, err := fs.lookup(name, false); err != nil {
+		return err
+	}
+	fs.overlay[key(name)] = &overlayEntryT{removed: true}
+	return nil
+}
+
+//====================================================================================================//
+
+// lockOverlay acquires overlayMutex, and discards the overlay if it was created for an earlier
+// re-execution of a wrapped function.
+func (fs *osFS) lockOverlay() {
+	fs.overlayMutex.Lock()
+	if run := onedge.ShadowRun(); run != fs.overlayRun {
+		fs.overlay = make(map[string]*overlayEntryT)
+		fs.overlayRun = run
+	}
+}
+
+// lookup returns the overlay's entry for name, copying the file from disk into the overlay if
+// necessary.  If the file does not exist and create is true, an empty entry is created.  overlayMutex
+// must be held.
+func (fs *osFS) lookup(name string, create bool) (*overlayEntryT, error) {
+	if entry, ok := fs.overlay[key(name)]; ok {
+		if entry.removed {
+			if !create {
+				return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+			}
+			entry.removed = false
+			entry.data = nil
+		}
+		return entry, nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		if !os.IsNotExist(err) || !create {
+			return nil, err
+		}
+	}
+	entry := &overlayEntryT{data: data}
+	fs.overlay[key(name)] = entry
+	return entry, nil
+}
+
+// key returns the overlay key for name.
+func key(name string) string {
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+	return filepath.Clean(name)
+}
+
+//====================================================================================================//
+
+// osFile is a file on disk.  Writes to it are recorded.  Within a shadow thread, writes to it are
+// dropped, and closing it does nothing unless it was opened within a shadow thread (i.e., shadow is
+// true).  Such a file may have been opened outside of the wrapped function (e.g., a log file), and so
+// is not covered by the overlay.
+type osFile struct {
+	*os.File
+	shadow bool
+}
+
+func (f *osFile) Write(p []byte) (int, error) {
+	onedge.RecordEffect(effectKind, fmt.Sprintf("write %d bytes to %s", len(p), f.Name()))
+	if onedge.InShadowThread() {
+		return len(p), nil
+	}
+	return f.File.Write(p)
+}
+
+func (f *osFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *osFile) WriteAt(p []byte, off int64) (int, error) {
+	onedge.RecordEffect(effectKind, fmt.Sprintf("write %d bytes to %s", len(p), f.Name()))
+	if onedge.InShadowThread() {
+		return len(p), nil
+	}
+	return f.File.WriteAt(p, off)
+}
+
+// ReadFrom is implemented in terms of Write, so that io.Copy does not bypass Write by calling the
+// embedded *os.File's ReadFrom.
+func (f *osFile) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{f}, r)
+}
+
+func (f *osFile) Truncate(size int64) error {
+	onedge.RecordEffect(effectKind, fmt.Sprintf("truncate %s", f.Name()))
+	if onedge.InShadowThread() {
+		return nil
+	}
+	return f.File.Truncate(size)
+}
+
+func (f *osFile) Close() error {
+	if onedge.InShadowThread() && !f.shadow {
+		return nil
+	}
+	return f.File.Close()
+}
+
+//====================================================================================================//
+
+// overlayFile is a file in the overlay.  Writes to it are recorded.
+type overlayFile struct {
+	fs     *osFS
+	name   string
+	entry  *overlayEntryT
+	append bool
+	offset int
+}
+
+func (f *overlayFile) Name() string {
+	return f.name
+}
+
+func (f *overlayFile) Read(p []byte) (int, error) {
+	f.fs.overlayMutex.Lock()
+	defer f.fs.overlayMutex.Unlock()
+	if f.offset >= len(f.entry.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.entry.data[f.offset:])
+	f.offset += n
+	return n, nil
+}
+
+func (f *overlayFile) Write(p []byte) (int, error) {
+	onedge.RecordEffect(effectKind, fmt.Sprintf("write %d bytes to %s", len(p), f.name))
+	f.fs.overlayMutex.Lock()
+	defer f.fs.overlayMutex.Unlock()
+	if f.append {
+		f.offset = len(f.entry.data)
+	}
+	for len(f.entry.data) < f.offset+len(p) {
+		f.entry.data = append(f.entry.data, 0)
+	}
+	copy(f.entry.data[f.offset:], p)
+	f.offset += len(p)
+	return len(p), nil
+}
+
+func (f *overlayFile) Close() error {
+	return nil
+}
+
+//====================================================================================================//
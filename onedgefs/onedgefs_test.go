@@ -0,0 +1,248 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedgefs
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	onedge "github.com/trailofbits/on-edge"
+)
+
+//====================================================================================================//
+
+func TestCreatePanicRecover(t *testing.T) {
+	fs := OS()
+	name := filepath.Join(t.TempDir(), "file")
+	output := captureStderr(t, func() {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			writeFile(t, fs, name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, "x")
+			panic(fmt.Errorf(""))
+		})
+	})
+	checkFile(t, name, "x")
+	checkOutput(t, output,
+		"External state change between WrapFunc entry and recover (fs): create "+name, true)
+	checkOutput(t, output, "(fs): write 1 bytes to "+name, true)
+}
+
+//====================================================================================================//
+
+func TestAppendPanicRecover(t *testing.T) {
+	fs := OS()
+	name := filepath.Join(t.TempDir(), "log")
+	output := captureStderr(t, func() {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			writeFile(t, fs, name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, "line\n")
+			panic(fmt.Errorf(""))
+		})
+	})
+	checkFile(t, name, "line\n")
+	checkOutput(t, output, "(fs): write 5 bytes to "+name, true)
+}
+
+//====================================================================================================//
+
+func TestRenamePanicRecover(t *testing.T) {
+	fs := OS()
+	dir := t.TempDir()
+	oldpath := filepath.Join(dir, "old")
+	newpath := filepath.Join(dir, "new")
+	if err := ioutil.WriteFile(oldpath, []byte("x"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	output := captureStderr(t, func() {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			// The main thread's rename is not undone by the panic.  So the shadow thread's rename
+			// fails, and the shadow thread does not panic.
+			if err := fs.Rename(oldpath, newpath); err != nil {
+				return
+			}
+			panic(fmt.Errorf(""))
+		})
+	})
+	checkFile(t, newpath, "x")
+	checkOutput(t, output, "(fs): rename "+oldpath+" to "+newpath, true)
+	checkOutput(t, output, "did not panic", true)
+}
+
+//====================================================================================================//
+
+func TestWriteOpenedOutsidePanicRecover(t *testing.T) {
+	fs := OS()
+	name := filepath.Join(t.TempDir(), "log")
+	file, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	output := captureStderr(t, func() {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			if _, err := file.Write([]byte("a")); err != nil {
+				t.Error(err)
+			}
+			if _, err := io.WriteString(file, "b"); err != nil {
+				t.Error(err)
+			}
+			panic(fmt.Errorf(""))
+		})
+	})
+	// The shadow thread's writes must not reach the disk.
+	checkFile(t, name, "ab")
+	checkOutput(t, output, "(fs): write 1 bytes to "+name, true)
+}
+
+//====================================================================================================//
+
+func TestRemoveNoPanic(t *testing.T) {
+	fs := OS()
+	name := filepath.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(name, []byte("x"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	output := captureStderr(t, func() {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			if err := fs.Remove(name); err != nil {
+				t.Error(err)
+			}
+		})
+	})
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkOutput(t, output, "External state change", false)
+}
+
+//====================================================================================================//
+
+func TestRemoveThenCreatePanicRecover(t *testing.T) {
+	fs := OS()
+	name := filepath.Join(t.TempDir(), "file")
+	captureStderr(t, func() {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			writeFile(t, fs, name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, "x")
+			if err := fs.Remove(name); err != nil {
+				t.Error(err)
+			}
+			panic(fmt.Errorf(""))
+		})
+	})
+	if err := ioutil.WriteFile(name, []byte("y"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	// The first shadow thread's removal of the file must not be visible to the second.
+	output := captureStderr(t, func() {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			file, err := fs.Open(name)
+			if err != nil {
+				return
+			}
+			file.Close()
+			panic(fmt.Errorf(""))
+		})
+	})
+	checkOutput(t, output, "did not panic", false)
+}
+
+//====================================================================================================//
+
+func writeFile(t *testing.T, fs FS, name string, flag int, s string) {
+	file, err := fs.OpenFile(name, flag, 0666)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write([]byte(s)); err != nil {
+		t.Error(err)
+	}
+}
+
+func checkFile(t *testing.T, name string, expected string) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != expected {
+		t.Fatalf("unexpected contents: %q != %q", data, expected)
+	}
+}
+
+func checkOutput(t *testing.T, output string, substr string, flag bool) {
+	if strings.Contains(output, substr) != flag {
+		t.Fatalf("output contains '%v' != %v: '%v'", substr, flag, output)
+	}
+}
+
+func captureStderr(t *testing.T, f func()) string {
+	file, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	stderr := os.Stderr
+	os.Stderr = file
+	defer func() {
+		os.Stderr = stderr
+	}()
+	f()
+	output, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(output)
+}
+
+//====================================================================================================//
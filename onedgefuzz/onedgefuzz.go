@@ -0,0 +1,63 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// Package onedgefuzz integrates OnEdge with Go's native fuzzing.
+//
+// OnEdge is only as effective as the inputs that drive a program's panics.  Target lets a fuzzer
+// search for such inputs.  Each input is run with OnEdge's findings converted into test failures (see
+// onedgetest.Check).  Data races are reported by Go's race detector, which already fails the input
+// during which a race is reported.  So running
+//
+//	go test -race -fuzz FuzzDecode
+//
+// causes the fuzzer to minimize, and save under testdata/fuzz, each input that causes a state change
+// before a panic.  While fuzzing, only the fact that a race was detected is shown.  Re-running the
+// saved input (e.g., "go test -race -run FuzzDecode/<input>") shows the race report itself.
+package onedgefuzz
+
+//====================================================================================================//
+
+import (
+	"testing"
+
+	"github.com/trailofbits/on-edge/onedgetest"
+)
+
+//====================================================================================================//
+
+// Target calls f.Fuzz with a fuzz target that runs fn on each input, such that any OnEdge finding
+// reported during fn fails the input.  Seed inputs should be added with f.Add before calling Target.
+// For example:
+//
+//	func FuzzDecode(f *testing.F) {
+//		f.Add([]byte("{}"))
+//		onedgefuzz.Target(f, func(t *testing.T, data []byte) {
+//			decode(data)
+//		})
+//	}
+//
+// fn should call WrapFunc (or one of its variants) itself, or call code that does.  Findings are
+// attributed to the input during which they are reported.  So, as with onedgetest.Check, fn should not
+// call t.Parallel.
+func Target(f *testing.F, fn func(t *testing.T, data []byte)) {
+	f.Helper()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		onedgetest.Check(t)
+		fn(t, data)
+	})
+}
+
+//====================================================================================================//
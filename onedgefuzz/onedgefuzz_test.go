@@ -0,0 +1,185 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedgefuzz
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	onedge "github.com/trailofbits/on-edge"
+)
+
+//====================================================================================================//
+
+// helperEnv is set when the test binary is re-executed to run one of the helper fuzz tests below.
+const helperEnv = "ONEDGEFUZZ_HELPER"
+
+// Global state for tests to modify.
+var exampleFlag bool
+
+//====================================================================================================//
+
+func TestTargetSeedCorpus(t *testing.T) {
+	output, err := runHelper(t, "FuzzEffectHelper", "-test.run", "^FuzzEffectHelper$")
+	if err != nil {
+		t.Fatalf("helper failed: %v: '%s'", err, output)
+	}
+	checkOutput(t, output, "--- PASS: FuzzEffectHelper", true)
+}
+
+func TestTargetEffect(t *testing.T) {
+	dir := t.TempDir()
+	output, err := runHelperFuzz(t, dir, "FuzzEffectHelper")
+	if err == nil {
+		t.Fatal("helper did not fail")
+	}
+	checkOutput(t, output, "OnEdge finding (external-effect) at ", true)
+	checkOutput(t, output, "External state change between WrapFunc entry and recover (test): effect",
+		true)
+	checkCorpus(t, dir, "FuzzEffectHelper")
+}
+
+func TestTargetRace(t *testing.T) {
+	dir := t.TempDir()
+	output, err := runHelperFuzz(t, dir, "FuzzRaceHelper")
+	if err == nil {
+		t.Fatal("helper did not fail")
+	}
+	checkOutput(t, output, "race detected during execution of test", true)
+	entry := checkCorpus(t, dir, "FuzzRaceHelper")
+	// The fuzzer does not show the race report itself.  Re-running the saved input does.
+	cmd := exec.Command(executable(t), "-test.v", "-test.run", "^FuzzRaceHelper/"+entry+"$")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), helperEnv+"=1")
+	rerunOutput, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatal("re-run did not fail")
+	}
+	checkOutput(t, string(rerunOutput), "WARNING: DATA RACE", true)
+	checkOutput(t, string(rerunOutput), "onedgefuzz.FuzzRaceHelper.func1()", true)
+}
+
+//====================================================================================================//
+
+// FuzzEffectHelper records an external effect before a panic when given an input of length at least
+// two.
+func FuzzEffectHelper(f *testing.F) {
+	if os.Getenv(helperEnv) == "" {
+		f.Skip("helper test")
+	}
+	f.Add([]byte("a"))
+	Target(f, func(t *testing.T, data []byte) {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			if len(data) >= 2 {
+				onedge.RecordEffect("test", "effect")
+			}
+			panic(fmt.Errorf(""))
+		})
+	})
+}
+
+// FuzzRaceHelper sets a global before a panic when given an input of length at least two.
+func FuzzRaceHelper(f *testing.F) {
+	if os.Getenv(helperEnv) == "" {
+		f.Skip("helper test")
+	}
+	f.Add([]byte("a"))
+	Target(f, func(t *testing.T, data []byte) {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			if len(data) >= 2 {
+				exampleFlag = true
+			}
+			panic(fmt.Errorf(""))
+		})
+	})
+}
+
+//====================================================================================================//
+
+// runHelper re-executes the test binary to run the helper fuzz test with the given name.
+func runHelper(t *testing.T, name string, args ...string) (string, error) {
+	cmd := exec.Command(os.Args[0], append([]string{"-test.v"}, args...)...)
+	cmd.Env = append(os.Environ(), helperEnv+"=1")
+	output, err := cmd.CombinedOutput()
+	if !strings.Contains(string(output), name) {
+		t.Fatalf("helper did not run: '%s'", output)
+	}
+	return string(output), err
+}
+
+// runHelperFuzz runs the helper fuzz test with the given name in fuzzing mode, in dir.
+func runHelperFuzz(t *testing.T, dir string, name string) (string, error) {
+	cmd := exec.Command(
+		executable(t),
+		"-test.run", "^$",
+		"-test.fuzz", "^"+name+"$",
+		"-test.fuzztime", "60s",
+		"-test.fuzzcachedir", filepath.Join(dir, "cache"),
+		"-test.parallel", "1",
+	)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), helperEnv+"=1")
+	output, err := cmd.CombinedOutput()
+	checkOutput(t, string(output), "Failing input written to testdata/fuzz/"+name, true)
+	return string(output), err
+}
+
+// checkCorpus checks that the fuzzer saved exactly one failing input for the fuzz test with the given
+// name, and returns the input's name.
+func checkCorpus(t *testing.T, dir string, name string) string {
+	entries, err := os.ReadDir(filepath.Join(dir, "testdata", "fuzz", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("unexpected corpus entries: %v", entries)
+	}
+	return entries[0].Name()
+}
+
+// executable returns the absolute path of the test binary, so that it can be run in another directory.
+func executable(t *testing.T) string {
+	exe, err := filepath.Abs(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exe
+}
+
+func checkOutput(t *testing.T, output string, substr string, flag bool) {
+	if strings.Contains(output, substr) != flag {
+		t.Fatalf("output contains '%v' != %v: '%v'", substr, flag, output)
+	}
+}
+
+//====================================================================================================//
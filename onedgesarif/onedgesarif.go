@@ -0,0 +1,405 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// Package onedgesarif writes OnEdge's findings and Go's data race reports as a SARIF 2.1.0 log, which
+// code scanning services (e.g., GitHub's) can display inline.
+//
+// Each finding or data race becomes a result.  A data race's primary location is its write access, and
+// its related locations are the call to WrapFunc whose re-execution detected the race, and the call to
+// WrapRecover that received the panic (if known).  Its code flow has a thread flow for each of the main
+// and shadow threads' stacks.  A finding's primary location is the call to WrapFunc that produced it,
+// its related location is the call to WrapRecover (if any), and its code flow has a thread flow for the
+// main thread's stack.
+//
+// A Log can be used as a Reporter:
+//
+//	log := onedgesarif.NewLog()
+//	onedge.SetReporter(log.Report)
+//	...
+//	err := log.Write(f)
+//
+// The onedge command's -sarif flag produces the same log for a run of "go test".
+package onedgesarif
+
+//====================================================================================================//
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	onedge "github.com/trailofbits/on-edge"
+	"github.com/trailofbits/on-edge/internal/racereport"
+)
+
+//====================================================================================================//
+
+// DataRaceRule is the rule ID of results for data races.  The rule ID of a result for a finding is the
+// finding's kind.
+const DataRaceRule onedge.FindingKind = "data-race"
+
+// srcRoot is the base ID of the URIs of files under the root directory (see NewLog).
+const srcRoot = "%SRCROOT%"
+
+// descriptions contains the short description of each rule.
+var descriptions = map[onedge.FindingKind]string{
+	onedge.FindingNoEnclosingWrapFunc:    "WrapRecover was called outside of any wrapped function.",
+	onedge.FindingShadowPanic:            "The shadow thread panicked and did not recover.",
+	onedge.FindingDifferentPanic:         "The shadow thread panicked with a different argument.",
+	onedge.FindingDidNotPanic:            "The shadow thread did not panic as it should have.",
+	onedge.FindingDidNotRecover:          "The shadow thread did not recover as it should have.",
+	onedge.FindingRecoveredMultipleTimes: "The shadow thread recovered multiple times.",
+	onedge.FindingDifferentResult:        "The shadow thread returned a different result.",
+	onedge.FindingNotIdempotent:          "Re-executing a wrapped function had a different outcome.",
+	onedge.FindingBrokenInvariant:        "An invariant that held on entry fails after recover.",
+	onedge.FindingArgumentChanged:        "An argument changed between entry and recover.",
+	onedge.FindingProcessStateChanged:    "The process state changed between entry and recover.",
+	onedge.FindingHeldLock:               "A lock acquired after entry is still held after recover.",
+	onedge.FindingExternalEffect:         "An external state change was recorded before recover.",
+	onedge.FindingLeakedFD:               "A file descriptor opened after entry is still open.",
+	onedge.FindingLeakedGoroutine:        "A goroutine started after entry is still running.",
+	onedge.FindingPanicAfterCheckpoint:   "A panic occurred after a checkpoint.",
+	DataRaceRule:                         "Global state changed before a panic was recovered from.",
+}
+
+// stackRegexp matches a frame of a Finding's Stack.
+var stackRegexp = regexp.MustCompile(`(?m)^    (.*)\(\)\n        (.*):(\d+)$`)
+
+//====================================================================================================//
+
+// The following types are the parts of the SARIF 2.1.0 object model that OnEdge uses.
+
+type logT struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []runT `json:"runs"`
+}
+
+type runT struct {
+	Tool               toolT                        `json:"tool"`
+	OriginalURIBaseIDs map[string]artifactLocationT `json:"originalUriBaseIds,omitempty"`
+	Results            []resultT                    `json:"results"`
+}
+
+type toolT struct {
+	Driver driverT `json:"driver"`
+}
+
+type driverT struct {
+	Name           string  `json:"name"`
+	InformationURI string  `json:"informationUri"`
+	Rules          []ruleT `json:"rules"`
+}
+
+type ruleT struct {
+	ID               string   `json:"id"`
+	ShortDescription messageT `json:"shortDescription"`
+}
+
+type messageT struct {
+	Text string `json:"text"`
+}
+
+type resultT struct {
+	RuleID           string            `json:"ruleId"`
+	RuleIndex        int               `json:"ruleIndex"`
+	Level            string            `json:"level"`
+	Message          messageT          `json:"message"`
+	Locations        []locationT       `json:"locations,omitempty"`
+	RelatedLocations []locationT       `json:"relatedLocations,omitempty"`
+	CodeFlows        []codeFlowT       `json:"codeFlows,omitempty"`
+	Properties       map[string]string `json:"properties,omitempty"`
+}
+
+type locationT struct {
+	ID               *int              `json:"id,omitempty"`
+	PhysicalLocation physicalLocationT `json:"physicalLocation"`
+	Message          *messageT         `json:"message,omitempty"`
+}
+
+type physicalLocationT struct {
+	ArtifactLocation artifactLocationT `json:"artifactLocation"`
+	Region           regionT           `json:"region"`
+}
+
+type artifactLocationT struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type regionT struct {
+	StartLine int `json:"startLine"`
+}
+
+type codeFlowT struct {
+	ThreadFlows []threadFlowT `json:"threadFlows"`
+}
+
+type threadFlowT struct {
+	ID        string                `json:"id"`
+	Locations []threadFlowLocationT `json:"locations"`
+}
+
+type threadFlowLocationT struct {
+	Location     locationT `json:"location"`
+	NestingLevel int       `json:"nestingLevel"`
+}
+
+//====================================================================================================//
+
+// Log is a SARIF log under construction.  A Log is not safe for concurrent use.  But since OnEdge's
+// Reporter is called only by the main thread, Report can be passed to SetReporter.
+type Log struct {
+	// root is the directory relative to which file names are written.
+	root    string
+	rules   []ruleT
+	results []resultT
+}
+
+// NewLog returns an empty Log.  File names under the current directory are written relative to it
+// (using the base ID "%SRCROOT%").
+func NewLog() *Log {
+	root, _ := os.Getwd()
+	return &Log{root: root}
+}
+
+// Report adds a result for finding.  Report has the signature of an onedge.Reporter.
+func (log *Log) Report(finding onedge.Finding) {
+	result := log.newResult(string(finding.Kind), finding.Message, finding.Test)
+	if finding.Site != (onedge.Site{}) {
+		result.Locations = []locationT{log.location(finding.Site.File, finding.Site.Line, "")}
+	}
+	result.RelatedLocations = log.relatedLocations(nil, finding.RecoverSite)
+	var frames []racereport.Frame
+	for _, m := range stackRegexp.FindAllStringSubmatch(finding.Stack, -1) {
+		line, _ := strconv.Atoi(m[3])
+		frames = append(frames, racereport.Frame{Function: m[1], File: m[2], Line: line})
+	}
+	if len(frames) > 0 {
+		result.CodeFlows = []codeFlowT{{ThreadFlows: []threadFlowT{log.threadFlow("main", frames)}}}
+	}
+	log.results = append(log.results, result)
+}
+
+// AddRace adds a result for each data race report in output, which may contain other text as well.
+// recoverSite is the location of the call to WrapRecover that received the panic preceding the
+// races, or the zero Site if it is unknown.
+func (log *Log) AddRace(output string, recoverSite onedge.Site) {
+	for _, report := range racereport.Parse(output) {
+		log.addRace(report, recoverSite)
+	}
+}
+
+// addRace adds a result for report.
+func (log *Log) addRace(report racereport.Report, recoverSite onedge.Site) {
+	var write, caller *racereport.Frame
+	for i := range report.Accesses {
+		access := &report.Accesses[i]
+		if len(access.Stack) == 0 {
+			continue
+		}
+		// Prefer the main thread's write, i.e., the global state change that preceded the panic.
+		if strings.Contains(strings.ToLower(access.Op), "write") &&
+			(write == nil || !access.InShadowThread()) {
+			write = &access.Stack[0]
+		}
+		if frame, ok := access.WrapFuncCaller(); ok && caller == nil {
+			caller = &frame
+		}
+	}
+	if write == nil && len(report.Accesses) > 0 && len(report.Accesses[0].Stack) > 0 {
+		write = &report.Accesses[0].Stack[0]
+	}
+	message := "Data race with the shadow thread"
+	if caller != nil {
+		message += " during the re-execution of the function wrapped at " +
+			log.relativePosition(caller.File, caller.Line)
+	}
+	result := log.newResult(string(DataRaceRule), message+".", report.Test())
+	if write != nil {
+		result.Locations = []locationT{log.location(write.File, write.Line, write.Function)}
+	}
+	result.RelatedLocations = log.relatedLocations(caller, recoverSite)
+	var threadFlows []threadFlowT
+	for _, access := range report.Accesses {
+		if len(access.Stack) == 0 {
+			continue
+		}
+		id := "main"
+		if access.InShadowThread() {
+			id = "shadow"
+		}
+		threadFlows = append(threadFlows, log.threadFlow(id, access.Stack))
+	}
+	// Which of the two accesses is the current one depends on scheduling.  So put the main thread's
+	// flow first.
+	sort.SliceStable(threadFlows, func(i, j int) bool {
+		return threadFlows[i].ID == "main" && threadFlows[j].ID != "main"
+	})
+	if len(threadFlows) > 0 {
+		result.CodeFlows = []codeFlowT{{ThreadFlows: threadFlows}}
+	}
+	log.results = append(log.results, result)
+}
+
+// Write writes the log, encoded as JSON, to w.
+func (log *Log) Write(w io.Writer) error {
+	run := runT{
+		Tool: toolT{Driver: driverT{
+			Name:           "OnEdge",
+			InformationURI: "https://github.com/trailofbits/on-edge",
+			Rules:          log.rules,
+		}},
+		Results: log.results,
+	}
+	if run.Tool.Driver.Rules == nil {
+		run.Tool.Driver.Rules = []ruleT{}
+	}
+	if run.Results == nil {
+		run.Results = []resultT{}
+	}
+	if log.root != "" {
+		run.OriginalURIBaseIDs = map[string]artifactLocationT{
+			srcRoot: {URI: fileURI(log.root) + "/"},
+		}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(logT{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []runT{run},
+	})
+}
+
+//====================================================================================================//
+
+// newResult returns a result for the rule ruleID, adding the rule to the log if necessary.
+func (log *Log) newResult(ruleID string, message string, test string) resultT {
+	index := -1
+	for i, rule := range log.rules {
+		if rule.ID == ruleID {
+			index = i
+		}
+	}
+	if index < 0 {
+		description, ok := descriptions[onedge.FindingKind(ruleID)]
+		if !ok {
+			description = ruleID
+		}
+		index = len(log.rules)
+		log.rules = append(log.rules, ruleT{ID: ruleID, ShortDescription: messageT{Text: description}})
+	}
+	result := resultT{
+		RuleID:    ruleID,
+		RuleIndex: index,
+		Level:     "error",
+		Message:   messageT{Text: message},
+	}
+	if test != "" {
+		result.Properties = map[string]string{"test": test}
+	}
+	return result
+}
+
+// relatedLocations returns the related locations for the call to WrapFunc made by caller (if
+// non-nil), and the call to WrapRecover at recoverSite (if not the zero Site).
+func (log *Log) relatedLocations(caller *racereport.Frame, recoverSite onedge.Site) []locationT {
+	var locations []locationT
+	if caller != nil {
+		locations = append(locations, log.location(caller.File, caller.Line, "The call to WrapFunc."))
+	}
+	if recoverSite != (onedge.Site{}) {
+		locations = append(locations,
+			log.location(recoverSite.File, recoverSite.Line, "The call to WrapRecover."))
+	}
+	for i := range locations {
+		id := i + 1
+		locations[i].ID = &id
+	}
+	return locations
+}
+
+// threadFlow returns a thread flow with the given ID, for stack.  The thread flow's locations are
+// ordered from the outermost frame to the innermost.
+func (log *Log) threadFlow(id string, stack []racereport.Frame) threadFlowT {
+	threadFlow := threadFlowT{ID: id}
+	for i := len(stack) - 1; i >= 0; i-- {
+		threadFlow.Locations = append(threadFlow.Locations, threadFlowLocationT{
+			Location:     log.location(stack[i].File, stack[i].Line, stack[i].Function),
+			NestingLevel: len(stack) - 1 - i,
+		})
+	}
+	return threadFlow
+}
+
+// location returns a location for line of file, with the given message (if any).
+func (log *Log) location(file string, line int, message string) locationT {
+	location := locationT{PhysicalLocation: physicalLocationT{
+		ArtifactLocation: log.artifactLocation(file),
+		Region:           regionT{StartLine: line},
+	}}
+	if message != "" {
+		location.Message = &messageT{Text: message}
+	}
+	return location
+}
+
+// artifactLocation returns an artifact location for file.  The URI is relative to the root directory if
+// file is under it, and absolute otherwise.
+func (log *Log) artifactLocation(file string) artifactLocationT {
+	if rel, ok := log.relative(file); ok {
+		return artifactLocationT{URI: rel, URIBaseID: srcRoot}
+	}
+	return artifactLocationT{URI: fileURI(file)}
+}
+
+// relativePosition returns file:line, with file made relative to the root directory if it is under
+// the root directory.
+func (log *Log) relativePosition(file string, line int) string {
+	if rel, ok := log.relative(file); ok {
+		file = rel
+	}
+	return file + ":" + strconv.Itoa(line)
+}
+
+// relative returns file relative to the root directory, if file is under the root directory.
+func (log *Log) relative(file string) (string, bool) {
+	if log.root == "" || !filepath.IsAbs(file) {
+		return "", false
+	}
+	rel, err := filepath.Rel(log.root, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// fileURI returns a "file" URI for the absolute path file.
+func fileURI(file string) string {
+	file = filepath.ToSlash(file)
+	if !strings.HasPrefix(file, "/") {
+		file = "/" + file
+	}
+	return "file://" + file
+}
+
+//====================================================================================================//
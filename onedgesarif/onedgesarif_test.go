@@ -0,0 +1,148 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+package onedgesarif
+
+//====================================================================================================//
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	onedge "github.com/trailofbits/on-edge"
+)
+
+//====================================================================================================//
+
+// raceOutput is a data race report whose current access is made by a shadow thread, and whose previous
+// access is made by the main thread.
+const raceOutput = `==================
+WARNING: DATA RACE
+Read at 0x000001b287ad by goroutine 9:
+  p.f.func1()
+      /p/p.go:8 +0x3d
+  github.com/trailofbits/on-edge.WrapFunc.func1()
+      /on-edge/onedge_race.go:183 +0x2e
+  github.com/trailofbits/on-edge.shadowThread()
+      /on-edge/onedge_race.go:513 +0x1e8
+
+Previous write at 0x000001b287ad by main goroutine:
+  p.f.func1()
+      /p/p.go:9 +0x3d
+  github.com/trailofbits/on-edge.WrapFunc.func1()
+      /on-edge/onedge_race.go:183 +0x2e
+  github.com/trailofbits/on-edge.WrapFuncROpts()
+      /on-edge/onedge_race.go:277 +0xa84
+  github.com/trailofbits/on-edge.WrapFunc()
+      /on-edge/onedge_race.go:182 +0x84
+  p.f()
+      /p/p.go:7 +0x1c
+  p.TestF()
+      /p/p_test.go:10 +0x1c
+  testing.tRunner()
+      /go/src/testing/testing.go:1792 +0x225
+==================
+`
+
+//====================================================================================================//
+
+func TestReport(t *testing.T) {
+	log := &Log{root: "/p"}
+	log.Report(onedge.Finding{
+		Kind:        onedge.FindingDidNotPanic,
+		Site:        onedge.Site{File: "/p/p.go", Line: 7},
+		Message:     "Shadow thread did not panic as it should have.",
+		Stack:       "    p.f()\n        /p/p.go:7\n    p.TestF()\n        /p/p_test.go:10\n",
+		RecoverSite: onedge.Site{File: "/p/p.go", Line: 11},
+		Test:        "TestF",
+	})
+	result := writeResult(t, log)
+	checkLocation(t, result.Locations[0], "p.go", 7)
+	if len(result.RelatedLocations) != 1 {
+		t.Fatalf("unexpected related locations: %+v", result.RelatedLocations)
+	}
+	checkLocation(t, result.RelatedLocations[0], "p.go", 11)
+	flows := result.CodeFlows[0].ThreadFlows
+	if len(flows) != 1 || flows[0].ID != "main" || len(flows[0].Locations) != 2 {
+		t.Fatalf("unexpected thread flows: %+v", flows)
+	}
+	checkLocation(t, flows[0].Locations[0].Location, "p_test.go", 10)
+	checkLocation(t, flows[0].Locations[1].Location, "p.go", 7)
+	if result.RuleID != "did-not-panic" || result.Properties["test"] != "TestF" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestAddRace(t *testing.T) {
+	log := &Log{root: "/p"}
+	log.AddRace(raceOutput, onedge.Site{File: "/p/p.go", Line: 11})
+	result := writeResult(t, log)
+	checkLocation(t, result.Locations[0], "p.go", 9)
+	if len(result.RelatedLocations) != 2 {
+		t.Fatalf("unexpected related locations: %+v", result.RelatedLocations)
+	}
+	checkLocation(t, result.RelatedLocations[0], "p.go", 7)
+	checkLocation(t, result.RelatedLocations[1], "p.go", 11)
+	flows := result.CodeFlows[0].ThreadFlows
+	ids := []string{flows[0].ID, flows[1].ID}
+	if !reflect.DeepEqual(ids, []string{"main", "shadow"}) {
+		t.Fatalf("unexpected thread flows: %+v", flows)
+	}
+	checkLocation(t, flows[0].Locations[len(flows[0].Locations)-1].Location, "p.go", 9)
+	if loc := flows[1].Locations[0].Location; loc.PhysicalLocation.ArtifactLocation.URI !=
+		"file:///on-edge/onedge_race.go" {
+		t.Fatalf("unexpected location: %+v", loc)
+	}
+	if result.RuleID != string(DataRaceRule) || result.Properties["test"] != "TestF" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+//====================================================================================================//
+
+// writeResult writes log, checks that it contains exactly one result, and returns that result.
+func writeResult(t *testing.T, log *Log) resultT {
+	var buffer bytes.Buffer
+	if err := log.Write(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	var decoded logT
+	if err := json.Unmarshal(buffer.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Version != "2.1.0" || len(decoded.Runs) != 1 || len(decoded.Runs[0].Results) != 1 {
+		t.Fatalf("unexpected log: %s", buffer.String())
+	}
+	run := decoded.Runs[0]
+	if len(run.Tool.Driver.Rules) != 1 || run.Tool.Driver.Rules[0].ID != run.Results[0].RuleID {
+		t.Fatalf("unexpected rules: %+v", run.Tool.Driver.Rules)
+	}
+	return run.Results[0]
+}
+
+// checkLocation checks that location is at line of the file uri, relative to the root directory.
+func checkLocation(t *testing.T, location locationT, uri string, line int) {
+	t.Helper()
+	expected := artifactLocationT{URI: uri, URIBaseID: srcRoot}
+	if location.PhysicalLocation.ArtifactLocation != expected ||
+		location.PhysicalLocation.Region.StartLine != line {
+		t.Fatalf("unexpected location: %+v", location)
+	}
+}
+
+//====================================================================================================//
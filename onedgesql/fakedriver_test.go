@@ -0,0 +1,120 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// This file contains an in-memory driver that merely logs the statements that it executes.
+
+//====================================================================================================//
+
+package onedgesql
+
+import (
+	"database/sql/driver"
+	"io"
+	"sync"
+)
+
+//====================================================================================================//
+
+type fakeDriverT struct {
+	mutex sync.Mutex
+	log   []string
+}
+
+func (d *fakeDriverT) Open(name string) (driver.Conn, error) {
+	return &fakeConnT{driver: d}, nil
+}
+
+func (d *fakeDriverT) append(s string) {
+	d.mutex.Lock()
+	d.log = append(d.log, s)
+	d.mutex.Unlock()
+}
+
+func (d *fakeDriverT) entries() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]string(nil), d.log...)
+}
+
+//====================================================================================================//
+
+type fakeConnT struct {
+	driver *fakeDriverT
+}
+
+func (c *fakeConnT) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmtT{conn: c, query: query}, nil
+}
+
+func (c *fakeConnT) Close() error {
+	return nil
+}
+
+func (c *fakeConnT) Begin() (driver.Tx, error) {
+	c.driver.append("BEGIN")
+	return &fakeTxT{conn: c}, nil
+}
+
+//====================================================================================================//
+
+type fakeStmtT struct {
+	conn  *fakeConnT
+	query string
+}
+
+func (s *fakeStmtT) Close() error {
+	return nil
+}
+
+func (s *fakeStmtT) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmtT) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.driver.append(s.query)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmtT) Query(args []driver.Value) (driver.Rows, error) {
+	s.conn.driver.append(s.query)
+	return &fakeRowsT{}, nil
+}
+
+//====================================================================================================//
+
+type fakeTxT struct {
+	conn *fakeConnT
+}
+
+func (t *fakeTxT) Commit() error {
+	t.conn.driver.append("COMMIT")
+	return nil
+}
+
+func (t *fakeTxT) Rollback() error {
+	t.conn.driver.append("ROLLBACK")
+	return nil
+}
+
+//====================================================================================================//
+
+type fakeRowsT struct{}
+
+func (r *fakeRowsT) Columns() []string              { return nil }
+func (r *fakeRowsT) Close() error                   { return nil }
+func (r *fakeRowsT) Next(dest []driver.Value) error { return io.EOF }
+
+//====================================================================================================//
@@ -0,0 +1,342 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// Package onedgesql provides a database/sql driver that wraps another driver and reports writes issued
+// before a panic.
+//
+// Writes to a database are global state changes that Go's race detector cannot see.  The driver
+// returned by Wrap records each statement that is not a read (e.g., an INSERT, UPDATE, or DELETE), and
+// each committed transaction, using onedge.RecordEffect.  So if such a statement is executed between
+// entry to a function wrapped by onedge.WrapFunc and a panic, then the statement is reported when the
+// panic is recovered.  Statements executed within a transaction are recorded only if the transaction is
+// committed.  A transaction that is rolled back has no effect, and so is not reported.
+//
+// Within a shadow thread, such statements are not forwarded to the wrapped driver.  Thus, they are not
+// executed twice.  (They are not recorded either, as onedge.RecordEffect ignores calls made within a
+// shadow thread.)  Reads are always forwarded.
+package onedgesql
+
+//====================================================================================================//
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"regexp"
+	"strings"
+
+	onedge "github.com/trailofbits/on-edge"
+)
+
+//====================================================================================================//
+
+// effectKind is the kind passed to onedge.RecordEffect.
+const effectKind = "sql"
+
+// readRegexp matches statements that do not change the database.  Statements beginning with WITH are
+// treated as reads unless they also match writeRegexp.
+var (
+	readRegexp = regexp.MustCompile(
+		`(?i)^(SELECT|SHOW|EXPLAIN|DESCRIBE|DESC|VALUES|WITH|BEGIN|START|ROLLBACK|SAVEPOINT|RELEASE)\b`,
+	)
+	writeRegexp = regexp.MustCompile(`(?i)\b(INSERT|UPDATE|DELETE|MERGE|UPSERT|REPLACE)\b`)
+)
+
+//====================================================================================================//
+
+// Wrap returns a driver that forwards to d, recording statements as described in the package
+// documentation.
+func Wrap(d driver.Driver) driver.Driver {
+	return &driverT{driver: d}
+}
+
+// Register registers Wrap(d) with database/sql under the given name.
+func Register(name string, d driver.Driver) {
+	sql.Register(name, Wrap(d))
+}
+
+//====================================================================================================//
+
+// IsRead returns true iff query does not change the database, e.g., a SELECT.
+func IsRead(query string) bool {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	if !readRegexp.MatchString(query) {
+		return false
+	}
+	if strings.HasPrefix(strings.ToUpper(query), "WITH") {
+		return !writeRegexp.MatchString(query)
+	}
+	return true
+}
+
+type driverT struct {
+	driver driver.Driver
+}
+
+func (d *driverT) Open(name string) (driver.Conn, error) {
+	conn, err := d.driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &connT{conn: conn}, nil
+}
+
+//====================================================================================================//
+
+// connT wraps a connection.  tx is the connection's open transaction, if any.  A transaction begun
+// within a shadow thread is never the connection's open transaction, as the connection may later be
+// used by the main thread.
+type connT struct {
+	conn driver.Conn
+	tx   *txT
+}
+
+// record records query if it is not a read, and returns true iff query should not be forwarded to the
+// wrapped driver (i.e., it is not a read and the caller is in a shadow thread).  If the connection has
+// an open transaction, then query is buffered until the transaction is committed.
+func (c *connT) record(query string) bool {
+	if IsRead(query) {
+		return false
+	}
+	if onedge.InShadowThread() {
+		return true
+	}
+	if c.tx != nil {
+		c.tx.queries = append(c.tx.queries, query)
+	} else {
+		onedge.RecordEffect(effectKind, query)
+	}
+	return false
+}
+
+func (c *connT) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := c.conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmtT{conn: c, stmt: stmt, query: query}, nil
+}
+
+func (c *connT) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	preparer, ok := c.conn.(driver.ConnPrepareContext)
+	if !ok {
+		return c.Prepare(query)
+	}
+	stmt, err := preparer.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmtT{conn: c, stmt: stmt, query: query}, nil
+}
+
+func (c *connT) Close() error {
+	return c.conn.Close()
+}
+
+func (c *connT) Begin() (driver.Tx, error) {
+	if onedge.InShadowThread() {
+		return &txT{}, nil
+	}
+	tx, err := c.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return c.begin(tx), nil
+}
+
+func (c *connT) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	beginner, ok := c.conn.(driver.ConnBeginTx)
+	if !ok {
+		return c.Begin()
+	}
+	if onedge.InShadowThread() {
+		return &txT{}, nil
+	}
+	tx, err := beginner.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.begin(tx), nil
+}
+
+// begin makes a txT wrapping tx the connection's open transaction.
+func (c *connT) begin(tx driver.Tx) *txT {
+	c.tx = &txT{conn: c, tx: tx}
+	return c.tx
+}
+
+func (c *connT) ExecContext(
+	ctx context.Context,
+	query string,
+	args []driver.NamedValue,
+) (driver.Result, error) {
+	execer, ok := c.conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if c.record(query) {
+		return driver.RowsAffected(0), nil
+	}
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c *connT) QueryContext(
+	ctx context.Context,
+	query string,
+	args []driver.NamedValue,
+) (driver.Rows, error) {
+	queryer, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if c.record(query) {
+		return emptyRowsT{}, nil
+	}
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c *connT) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+func (c *connT) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *connT) IsValid() bool {
+	if validator, ok := c.conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+//====================================================================================================//
+
+type stmtT struct {
+	conn  *connT
+	stmt  driver.Stmt
+	query string
+}
+
+func (s *stmtT) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *stmtT) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *stmtT) Exec(args []driver.Value) (driver.Result, error) {
+	if s.conn.record(s.query) {
+		return driver.RowsAffected(0), nil
+	}
+	return s.stmt.Exec(args)
+}
+
+func (s *stmtT) Query(args []driver.Value) (driver.Rows, error) {
+	if s.conn.record(s.query) {
+		return emptyRowsT{}, nil
+	}
+	return s.stmt.Query(args)
+}
+
+func (s *stmtT) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if s.conn.record(s.query) {
+		return driver.RowsAffected(0), nil
+	}
+	if execer, ok := s.stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	return s.stmt.Exec(values(args))
+}
+
+func (s *stmtT) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if s.conn.record(s.query) {
+		return emptyRowsT{}, nil
+	}
+	if queryer, ok := s.stmt.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+	return s.stmt.Query(values(args))
+}
+
+func (s *stmtT) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+// values converts named values to positional values, for drivers that do not support the former.
+func values(args []driver.NamedValue) []driver.Value {
+	result := make([]driver.Value, len(args))
+	for i, arg := range args {
+		result[i] = arg.Value
+	}
+	return result
+}
+
+//====================================================================================================//
+
+// txT wraps a transaction.  Within a shadow thread, conn and tx are nil, i.e., the transaction was
+// never begun, and is not the connection's open transaction.  queries are the statements executed
+// within the transaction that are not reads.  They are recorded when the transaction is successfully
+// committed, and discarded when it is rolled back.
+type txT struct {
+	conn    *connT
+	tx      driver.Tx
+	queries []string
+}
+
+func (t *txT) Commit() error {
+	if t.conn == nil {
+		return nil
+	}
+	t.conn.tx = nil
+	if err := t.tx.Commit(); err != nil {
+		return err
+	}
+	for _, query := range append(t.queries, "COMMIT") {
+		onedge.RecordEffect(effectKind, query)
+	}
+	return nil
+}
+
+func (t *txT) Rollback() error {
+	if t.conn == nil {
+		return nil
+	}
+	t.conn.tx = nil
+	return t.tx.Rollback()
+}
+
+//====================================================================================================//
+
+// emptyRowsT is returned in place of the results of a statement that was not forwarded.
+type emptyRowsT struct{}
+
+func (emptyRowsT) Columns() []string              { return nil }
+func (emptyRowsT) Close() error                   { return nil }
+func (emptyRowsT) Next(dest []driver.Value) error { return io.EOF }
+
+//====================================================================================================//
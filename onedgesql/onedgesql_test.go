@@ -0,0 +1,244 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedgesql
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	onedge "github.com/trailofbits/on-edge"
+)
+
+//====================================================================================================//
+
+func TestIsRead(t *testing.T) {
+	for query, expected := range map[string]bool{
+		"SELECT * FROM accounts":                           true,
+		"  (select 1)":                                     true,
+		"WITH t AS (SELECT 1) SELECT * FROM t":             true,
+		"WITH t AS (DELETE FROM accounts) SELECT * FROM t": false,
+		"INSERT INTO accounts VALUES (1)":                  false,
+		"update accounts SET balance = 0":                  false,
+		"DELETE FROM accounts":                             false,
+		"COMMIT":                                           false,
+		"ROLLBACK":                                         true,
+	} {
+		if IsRead(query) != expected {
+			t.Errorf("IsRead(%q) != %v", query, expected)
+		}
+	}
+}
+
+//====================================================================================================//
+
+func TestExecPanicRecover(t *testing.T) {
+	d, db := open(t)
+	output := captureStderr(t, func() {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			if _, err := db.Exec("INSERT INTO accounts VALUES (1)"); err != nil {
+				t.Error(err)
+			}
+			panic(fmt.Errorf(""))
+		})
+	})
+	checkLog(t, d, []string{"INSERT INTO accounts VALUES (1)"})
+	checkOutput(t, output, "External state change between WrapFunc entry and recover (sql): "+
+		"INSERT INTO accounts VALUES (1)", true)
+}
+
+//====================================================================================================//
+
+func TestExecNoPanic(t *testing.T) {
+	d, db := open(t)
+	output := captureStderr(t, func() {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			if _, err := db.Exec("INSERT INTO accounts VALUES (1)"); err != nil {
+				t.Error(err)
+			}
+		})
+	})
+	checkLog(t, d, []string{"INSERT INTO accounts VALUES (1)"})
+	checkOutput(t, output, "External state change", false)
+}
+
+//====================================================================================================//
+
+func TestCommitPanicRecover(t *testing.T) {
+	d, db := open(t)
+	output := captureStderr(t, func() {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tx.Exec("UPDATE accounts SET balance = 0"); err != nil {
+				t.Error(err)
+			}
+			if err := tx.Commit(); err != nil {
+				t.Error(err)
+			}
+			panic(fmt.Errorf(""))
+		})
+	})
+	checkLog(t, d, []string{"BEGIN", "UPDATE accounts SET balance = 0", "COMMIT"})
+	checkOutput(t, output, "(sql): UPDATE accounts SET balance = 0", true)
+	checkOutput(t, output, "(sql): COMMIT", true)
+}
+
+//====================================================================================================//
+
+func TestRollbackPanicRecover(t *testing.T) {
+	d, db := open(t)
+	output := captureStderr(t, func() {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tx.Exec("UPDATE accounts SET balance = 0"); err != nil {
+				t.Error(err)
+			}
+			if err := tx.Rollback(); err != nil {
+				t.Error(err)
+			}
+			panic(fmt.Errorf(""))
+		})
+	})
+	checkLog(t, d, []string{"BEGIN", "UPDATE accounts SET balance = 0", "ROLLBACK"})
+	checkOutput(t, output, "External state change", false)
+}
+
+//====================================================================================================//
+
+func TestShadowTxPanicRecover(t *testing.T) {
+	d := &fakeDriverT{}
+	conn, err := Wrap(d).Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := captureStderr(t, func() {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			tx, err := conn.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The main thread rolls back its transaction, but the shadow thread leaves its own open.
+			if !onedge.InShadowThread() {
+				if err := tx.Rollback(); err != nil {
+					t.Error(err)
+				}
+			}
+			panic(fmt.Errorf(""))
+		})
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			stmt, err := conn.Prepare("INSERT INTO accounts VALUES (1)")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := stmt.Exec(nil); err != nil {
+				t.Error(err)
+			}
+			panic(fmt.Errorf(""))
+		})
+	})
+	checkLog(t, d, []string{"BEGIN", "ROLLBACK", "INSERT INTO accounts VALUES (1)"})
+	checkOutput(t, output, "(sql): INSERT INTO accounts VALUES (1)", true)
+}
+
+//====================================================================================================//
+
+func open(t *testing.T) (*fakeDriverT, *sql.DB) {
+	d := &fakeDriverT{}
+	name := "onedgesql-" + t.Name()
+	Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		db.Close()
+	})
+	return d, db
+}
+
+func checkLog(t *testing.T, d *fakeDriverT, expected []string) {
+	if log := d.entries(); !reflect.DeepEqual(log, expected) {
+		t.Fatalf("unexpected log: %q != %q", log, expected)
+	}
+}
+
+func checkOutput(t *testing.T, output string, substr string, flag bool) {
+	if strings.Contains(output, substr) != flag {
+		t.Fatalf("output contains '%v' != %v: '%v'", substr, flag, output)
+	}
+}
+
+func captureStderr(t *testing.T, f func()) string {
+	file, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	stderr := os.Stderr
+	os.Stderr = file
+	defer func() {
+		os.Stderr = stderr
+	}()
+	f()
+	output, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(output)
+}
+
+//====================================================================================================//
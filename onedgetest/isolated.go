@@ -0,0 +1,195 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// This file contains RunIsolated, which generalizes the runExample and checkExample functions used by
+// OnEdge's own tests.  A data race report does not stop a program, but it does cause the program to
+// fail, and so the only reliable way to assert on a report is to produce it in another process.
+
+//====================================================================================================//
+
+package onedgetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+
+	onedge "github.com/trailofbits/on-edge"
+	"github.com/trailofbits/on-edge/internal/racereport"
+)
+
+//====================================================================================================//
+
+// FindingDataRace is the kind of a Finding that is a data race reported by Go's race detector.
+const FindingDataRace onedge.FindingKind = "data-race"
+
+// Finding is a finding produced by a function run with RunIsolated: either an OnEdge finding, or a
+// data race.  For both, Site is the location of the enclosing most call to WrapFunc (or one of its
+// variants).  For a data race, Site is taken from the stack of the racing access made by the main
+// thread, and is the zero Site if neither access was made within a call to WrapFunc.  Also for a data
+// race, Message is the race detector's report, and Stack is empty.
+type Finding struct {
+	onedge.Finding
+	// AccessSite is the location of the first of the two racing accesses in a data race, and
+	// AccessStack is the stack of that access, in the style of a Go stack trace.  Both are empty for
+	// OnEdge findings.
+	AccessSite  onedge.Site
+	AccessStack string
+	// Variable is the name of the global variable involved in a data race, e.g.,
+	// "github.com/trailofbits/on-edge.exampleFlag".  Variable is empty for OnEdge findings, for data
+	// races on variables that are not global, and when the test binary has no symbol table (e.g., when
+	// it was built by "go test" rather than "go test -c").
+	Variable string
+}
+
+// Result is the result of running a function with RunIsolated.
+type Result struct {
+	// Findings contains the OnEdge findings in the order that they were reported, followed by the data
+	// races in the order that they were reported.
+	Findings []Finding
+	// Output is the re-executed test binary's combined stdout and stderr.
+	Output string
+}
+
+//====================================================================================================//
+
+// isolatedEnv is set in the environment of a test binary re-executed by RunIsolated.  Its value is the
+// name of the test that called RunIsolated.
+const isolatedEnv = "ONEDGETEST_ISOLATED"
+
+// findingPrefix begins each line with which a re-executed test binary reports an OnEdge finding.  The
+// remainder of the line is the finding encoded as JSON.
+const findingPrefix = "onedgetest: finding: "
+
+// doneLine is printed by a re-executed test binary after its function returns.
+const doneLine = "onedgetest: done"
+
+//====================================================================================================//
+
+// RunIsolated re-executes the current test binary to run f by itself, and returns the OnEdge findings
+// and data races that f produced.  The re-executed binary runs only the calling test, t, up to the call
+// to RunIsolated, where it calls f and exits.  So the code that precedes the call to RunIsolated should
+// be deterministic and free of side effects outside of the process.  The current test binary should
+// have been built with -race.
+func RunIsolated(t *testing.T, f func()) Result {
+	t.Helper()
+	if os.Getenv(isolatedEnv) == t.Name() {
+		runIsolatedChild(f)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(executable, "-test.run", runPattern(t.Name()), "-test.count", "1")
+	cmd.Env = append(os.Environ(), isolatedEnv+"="+t.Name())
+	// The exit status is ignored.  A data race causes the re-executed binary to fail.
+	output, _ := cmd.CombinedOutput()
+	if !strings.Contains(string(output), doneLine) {
+		t.Fatalf("isolated run of %s did not complete:\n%s", t.Name(), output)
+	}
+	return parseOutput(t, executable, string(output))
+}
+
+// runIsolatedChild runs f in a re-executed test binary, and exits.
+func runIsolatedChild(f func()) {
+	onedge.SetReporter(func(finding onedge.Finding) {
+		data, err := json.Marshal(finding)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Fprintf(os.Stderr, "%s%s\n", findingPrefix, data)
+	})
+	f()
+	fmt.Fprintln(os.Stderr, doneLine)
+	os.Exit(0)
+}
+
+// runPattern returns a -test.run pattern that matches exactly the test named name.
+func runPattern(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(part) + "$"
+	}
+	return strings.Join(parts, "/")
+}
+
+//====================================================================================================//
+
+// parseOutput extracts the findings from output, the output of the test binary at executable.
+func parseOutput(t *testing.T, executable string, output string) Result {
+	result := Result{Output: output}
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, findingPrefix) {
+			continue
+		}
+		var finding Finding
+		if err := json.Unmarshal([]byte(line[len(findingPrefix):]), &finding.Finding); err != nil {
+			t.Fatalf("could not parse finding: %v: %s", err, line)
+		}
+		result.Findings = append(result.Findings, finding)
+	}
+	reports := racereport.Parse(output)
+	if len(reports) <= 0 {
+		return result
+	}
+	// Go's race detector does not name the variables involved in races.  But the addresses of global
+	// variables can be looked up in the executable's symbol table.
+	symbolizer, err := racereport.NewSymbolizer(executable)
+	if err != nil {
+		t.Logf("cannot look up racing variables: %v", err)
+	}
+	for _, report := range reports {
+		result.Findings = append(result.Findings, raceFinding(report, symbolizer))
+	}
+	return result
+}
+
+// raceFinding converts report into a Finding.  symbolizer may be nil.
+func raceFinding(report racereport.Report, symbolizer *racereport.Symbolizer) Finding {
+	finding := Finding{Finding: onedge.Finding{
+		Kind:    FindingDataRace,
+		Message: strings.Trim(report.Text, "=\n"),
+		Test:    report.Test(),
+	}}
+	if len(report.Accesses) <= 0 {
+		return finding
+	}
+	for _, access := range report.Accesses {
+		if caller, ok := access.WrapFuncCaller(); ok {
+			finding.Site = onedge.Site{File: caller.File, Line: caller.Line}
+			break
+		}
+	}
+	access := report.Accesses[0]
+	if len(access.Stack) > 0 {
+		finding.AccessSite = onedge.Site{File: access.Stack[0].File, Line: access.Stack[0].Line}
+	}
+	var builder strings.Builder
+	for _, frame := range access.Stack {
+		fmt.Fprintf(&builder, "    %s()\n        %s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+	finding.AccessStack = builder.String()
+	if symbolizer != nil {
+		finding.Variable, _ = symbolizer.Lookup(access.Addr)
+	}
+	return finding
+}
+
+//====================================================================================================//
@@ -0,0 +1,127 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedgetest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	onedge "github.com/trailofbits/on-edge"
+	"github.com/trailofbits/on-edge/internal/racereport"
+)
+
+//====================================================================================================//
+
+// isolatedFlag is a global for isolated functions to modify.
+var isolatedFlag bool
+
+//====================================================================================================//
+
+func TestRunIsolatedSetFlagPanicRecover(t *testing.T) {
+	result := RunIsolated(t, func() {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			isolatedFlag = true
+			panic(fmt.Errorf(""))
+		})
+	})
+	if len(result.Findings) != 1 {
+		t.Fatalf("unexpected findings: %+v", result.Findings)
+	}
+	finding := result.Findings[0]
+	if finding.Kind != FindingDataRace {
+		t.Fatalf("unexpected kind: %v", finding.Kind)
+	}
+	// Site is the call to WrapFunc, and AccessSite is the assignment to isolatedFlag that follows it.
+	if filepath.Base(finding.Site.File) != "isolated_test.go" {
+		t.Fatalf("unexpected site: %v", finding.Site)
+	}
+	if filepath.Base(finding.AccessSite.File) != "isolated_test.go" ||
+		finding.AccessSite.Line <= finding.Site.Line {
+		t.Fatalf("unexpected access site: %v", finding.AccessSite)
+	}
+	variable := "github.com/trailofbits/on-edge/onedgetest.isolatedFlag"
+	if !haveSymbols() {
+		variable = ""
+	}
+	if finding.Test != "TestRunIsolatedSetFlagPanicRecover" {
+		t.Fatalf("unexpected test: %q", finding.Test)
+	}
+	if finding.Variable != variable {
+		t.Fatalf("unexpected variable: %q", finding.Variable)
+	}
+}
+
+// haveSymbols returns true iff the test binary has a symbol table.
+func haveSymbols() bool {
+	executable, err := os.Executable()
+	if err != nil {
+		return false
+	}
+	_, err = racereport.NewSymbolizer(executable)
+	return err == nil
+}
+
+//====================================================================================================//
+
+func TestRunIsolatedEffectPanicRecover(t *testing.T) {
+	result := RunIsolated(t, recordEffectPanicRecover)
+	if len(result.Findings) != 1 {
+		t.Fatalf("unexpected findings: %+v", result.Findings)
+	}
+	finding := result.Findings[0]
+	if finding.Kind != onedge.FindingExternalEffect {
+		t.Fatalf("unexpected kind: %v", finding.Kind)
+	}
+	if filepath.Base(finding.Site.File) != "onedgetest_test.go" {
+		t.Fatalf("unexpected site: %v", finding.Site)
+	}
+	if finding.Test != "TestRunIsolatedEffectPanicRecover" {
+		t.Fatalf("unexpected test: %q", finding.Test)
+	}
+	if finding.Message != "External state change between WrapFunc entry and recover (test): effect" {
+		t.Fatalf("unexpected message: %q", finding.Message)
+	}
+}
+
+//====================================================================================================//
+
+func TestRunIsolatedNoPanic(t *testing.T) {
+	result := RunIsolated(t, func() {
+		onedge.WrapFunc(func() {
+			defer func() {
+				if r := onedge.WrapRecover(recover()); r != nil {
+				}
+			}()
+			isolatedFlag = true
+		})
+	})
+	if len(result.Findings) != 0 {
+		t.Fatalf("unexpected findings: %+v", result.Findings)
+	}
+}
+
+//====================================================================================================//
@@ -0,0 +1,144 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// Package onedgetest integrates OnEdge with the testing package.
+//
+// Check causes OnEdge's findings (e.g., a shadow thread that did not panic as it should have) to fail
+// the test during which they are reported.  Main, called from TestMain, causes findings reported
+// outside of any test using Check to fail the test binary.
+//
+// Data races are reported by Go's race detector, not by OnEdge.  The testing package already fails a
+// test during which a data race is reported.
+//
+// This package also defines two flags, which Main acts upon.  -onedge.coverprofile writes OnEdge's
+// recover-path coverage (see onedge.WriteCoverProfile) to a file, which can be viewed with "go tool
+// cover".  -onedge.coverage prints the same coverage as a table (see onedge.WriteCoverage).
+//
+// OnEdge has a single Reporter (see onedge.SetReporter).  So Check should not be used by tests that run
+// in parallel.
+package onedgetest
+
+//====================================================================================================//
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	onedge "github.com/trailofbits/on-edge"
+)
+
+//====================================================================================================//
+
+var (
+	coverProfile = flag.String(
+		"onedge.coverprofile",
+		"",
+		"write OnEdge's recover-path coverage to `file`",
+	)
+	coverage = flag.Bool("onedge.coverage", false, "print OnEdge's recover-path coverage")
+)
+
+//====================================================================================================//
+
+// Check installs a Reporter that converts each finding into a call to t.Errorf, including the stack at
+// the call to WrapFunc that produced the finding.  The previous Reporter is restored when t and its
+// subtests complete.
+func Check(t testing.TB) {
+	t.Helper()
+	prev := onedge.SetReporter(func(finding onedge.Finding) {
+		t.Errorf("%s", format(finding))
+	})
+	t.Cleanup(func() {
+		onedge.SetReporter(prev)
+	})
+}
+
+// Main runs the tests in m, and then exits.  Findings reported outside of any test using Check are
+// reported as usual (i.e., by the previous Reporter), and cause the test binary to fail.  Main is
+// meant to be called from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		onedgetest.Main(m)
+//	}
+func Main(m *testing.M) {
+	var n int64
+	var prev onedge.Reporter
+	prev = onedge.SetReporter(func(finding onedge.Finding) {
+		atomic.AddInt64(&n, 1)
+		prev(finding)
+	})
+	code := m.Run()
+	if err := writeCoverage(); err != nil {
+		fmt.Fprintf(os.Stderr, "onedgetest: %v\n", err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	if n := atomic.LoadInt64(&n); n > 0 {
+		fmt.Fprintf(
+			os.Stderr,
+			"onedgetest: %d OnEdge finding(s) reported outside of a checked test\n",
+			n,
+		)
+		if code == 0 {
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+//====================================================================================================//
+
+// writeCoverage writes OnEdge's recover-path coverage as requested by the -onedge.coverprofile and
+// -onedge.coverage flags.
+func writeCoverage() error {
+	if *coverage {
+		if err := onedge.WriteCoverage(os.Stdout); err != nil {
+			return err
+		}
+	}
+	if *coverProfile == "" {
+		return nil
+	}
+	file, err := os.Create(*coverProfile)
+	if err != nil {
+		return err
+	}
+	if err := onedge.WriteCoverProfile(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+//====================================================================================================//
+
+// format formats finding as a multi-line message: the finding's kind and site, its message, and the
+// stack at the call to WrapFunc that produced it.
+func format(finding onedge.Finding) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "OnEdge finding (%s) at %v:\n%s", finding.Kind, finding.Site, finding.Message)
+	if finding.Stack != "" {
+		fmt.Fprintf(&builder, "\nWrapFunc called at:\n%s", strings.TrimRight(finding.Stack, "\n"))
+	}
+	return builder.String()
+}
+
+//====================================================================================================//
@@ -0,0 +1,117 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedgetest
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	onedge "github.com/trailofbits/on-edge"
+)
+
+//====================================================================================================//
+
+// helperEnv is set when the test binary is re-executed to run one of the helper tests below.
+const helperEnv = "ONEDGETEST_HELPER"
+
+func TestMain(m *testing.M) {
+	Main(m)
+}
+
+//====================================================================================================//
+
+func TestCheck(t *testing.T) {
+	output, err := runHelper(t, "TestCheckHelper")
+	if err == nil {
+		t.Fatal("helper did not fail")
+	}
+	checkOutput(t, output, "--- FAIL: TestCheckHelper", true)
+	checkOutput(t, output, "OnEdge finding (external-effect) at ", true)
+	checkOutput(t, output, "External state change between WrapFunc entry and recover (test): effect",
+		true)
+	checkOutput(t, output, "WrapFunc called at:", true)
+	checkOutput(t, output, "onedgetest.recordEffectPanicRecover()", true)
+	checkOutput(t, output, "outside of a checked test", false)
+}
+
+func TestCheckHelper(t *testing.T) {
+	if os.Getenv(helperEnv) == "" {
+		t.Skip("helper test")
+	}
+	Check(t)
+	recordEffectPanicRecover()
+}
+
+//====================================================================================================//
+
+func TestMainOutsideCheck(t *testing.T) {
+	output, err := runHelper(t, "TestMainOutsideCheckHelper")
+	if err == nil {
+		t.Fatal("helper did not fail")
+	}
+	checkOutput(t, output, "--- PASS: TestMainOutsideCheckHelper", true)
+	checkOutput(t, output,
+		"=== External state change between WrapFunc entry and recover (test): effect", true)
+	checkOutput(t, output, "onedgetest: 1 OnEdge finding(s) reported outside of a checked test", true)
+}
+
+func TestMainOutsideCheckHelper(t *testing.T) {
+	if os.Getenv(helperEnv) == "" {
+		t.Skip("helper test")
+	}
+	recordEffectPanicRecover()
+}
+
+//====================================================================================================//
+
+// recordEffectPanicRecover produces a finding that is not a data race.
+func recordEffectPanicRecover() {
+	onedge.WrapFunc(func() {
+		defer func() {
+			if r := onedge.WrapRecover(recover()); r != nil {
+			}
+		}()
+		onedge.RecordEffect("test", "effect")
+		panic(fmt.Errorf(""))
+	})
+}
+
+// runHelper re-executes the test binary to run the helper test with the given name.
+func runHelper(t *testing.T, name string) (string, error) {
+	cmd := exec.Command(os.Args[0], "-test.v", "-test.run", "^"+name+"$")
+	cmd.Env = append(os.Environ(), helperEnv+"=1")
+	output, err := cmd.CombinedOutput()
+	if !strings.Contains(string(output), name) {
+		t.Fatalf("helper did not run: '%s'", output)
+	}
+	return string(output), err
+}
+
+func checkOutput(t *testing.T, output string, substr string, flag bool) {
+	if strings.Contains(output, substr) != flag {
+		t.Fatalf("output contains '%v' != %v: '%v'", substr, flag, output)
+	}
+}
+
+//====================================================================================================//
@@ -0,0 +1,97 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+// This file implements the CheckProcessState optional check (see "checks.go"), which is enabled by
+// default, and which detects changes to process state, i.e., state that is not Go memory and that Go's
+// race detector therefore does not see.
+// Examples include environment variables (os.Setenv), the working directory (os.Chdir), the umask
+// (syscall.Umask), GOMAXPROCS (runtime.GOMAXPROCS), and garbage collector settings (debug.SetGCPercent
+// and debug.SetMemoryLimit).  When the check is enabled, a snapshot of the process state is taken when
+// WrapFuncR is called, and compared to a second snapshot when WrapRecover receives a panic.  The second
+// snapshot is taken with the race detector's handling of synchronization disabled (see WrapRecover in
+// "onedge_race.go").  In particular, os.Environ acquires the lock that os.Setenv acquires, and so would
+// otherwise cause the race detector to think that the main thread and the shadow thread were
+// synchronized.
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"strings"
+)
+
+//====================================================================================================//
+
+// processStateMetrics are the runtime/metrics that are included in process state snapshots.  Reading
+// these metrics, unlike calling, e.g., debug.SetGCPercent, does not change the garbage collector's
+// settings.
+var processStateMetrics = map[string]string{
+	"/gc/gogc:percent":     "GOGC",
+	"/gc/gomemlimit:bytes": "GOMEMLIMIT",
+}
+
+//====================================================================================================//
+
+// snapshotProcessState returns a snapshot of the process state.
+func snapshotProcessState() snapshotT {
+	snapshot := make(snapshotT)
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			snapshot["env "+kv[:i]] = kv[i+1:]
+		}
+	}
+	if wd, err := os.Getwd(); err == nil {
+		snapshot["working directory"] = wd
+	}
+	if umask, ok := readUmask(); ok {
+		snapshot["umask"] = fmt.Sprintf("%#o", umask)
+	}
+	snapshot["GOMAXPROCS"] = fmt.Sprintf("%d", runtime.GOMAXPROCS(0))
+	samples := make([]metrics.Sample, 0, len(processStateMetrics))
+	for name := range processStateMetrics {
+		samples = append(samples, metrics.Sample{Name: name})
+	}
+	metrics.Read(samples)
+	for _, sample := range samples {
+		if sample.Value.Kind() == metrics.KindUint64 {
+			snapshot[processStateMetrics[sample.Name]] = fmt.Sprintf("%d", sample.Value.Uint64())
+		}
+	}
+	return snapshot
+}
+
+//====================================================================================================//
+
+// reportProcessStateChanges reports the differences between the process state snapshot taken when the
+// wrappedFuncT's WrapFuncR was called, and the current process state.
+func reportProcessStateChanges(wrappedFunc wrappedFuncT) {
+	for _, diff := range diffSnapshots(wrappedFunc.processStateAtEntry, snapshotProcessState()) {
+		report(
+			FindingProcessStateChanged,
+			"Process state changed between WrapFunc entry and recover: %s",
+			diff,
+		)
+	}
+}
+
+//====================================================================================================//
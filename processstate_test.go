@@ -0,0 +1,181 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"testing"
+)
+
+//====================================================================================================//
+
+func TestProcessStateSetenvPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "Process state changed between WrapFunc entry and recover: "+
+		"env ONEDGE_EXAMPLE: <absent> -> 1", true)
+}
+
+func ExampleProcessStateSetenvPanicRecover() {
+	os.Unsetenv("ONEDGE_EXAMPLE")
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		os.Setenv("ONEDGE_EXAMPLE", "1")
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestProcessStateChdirPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output,
+		"Process state changed between WrapFunc entry and recover: working directory: ", true)
+}
+
+func ExampleProcessStateChdirPanicRecover() {
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		os.Chdir(os.TempDir())
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestProcessStateSetGOMAXPROCSPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "Process state changed between WrapFunc entry and recover: GOMAXPROCS: ",
+		true)
+}
+
+func ExampleProcessStateSetGOMAXPROCSPanicRecover() {
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		runtime.GOMAXPROCS(runtime.GOMAXPROCS(0) + 1)
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestProcessStateSetGCPercentPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "Process state changed between WrapFunc entry and recover: GOGC: 100 -> 50",
+		true)
+}
+
+func ExampleProcessStateSetGCPercentPanicRecover() {
+	debug.SetGCPercent(100)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		debug.SetGCPercent(50)
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestProcessStateSetGCPercentRestorePanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "Process state changed", false)
+}
+
+func ExampleProcessStateSetGCPercentRestorePanicRecover() {
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		defer debug.SetGCPercent(debug.SetGCPercent(50))
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestProcessStateSetenvIncrementPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+	checkOutput(t, output, "env ONEDGE_EXAMPLE: <absent> -> 1", true)
+}
+
+// The snapshot taken after the recover must not cause the race detector to think that the shadow thread
+// and the main thread were synchronized, even though os.Environ and os.Setenv acquire the same lock.
+func ExampleProcessStateSetenvIncrementPanicRecover() {
+	os.Unsetenv("ONEDGE_EXAMPLE")
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		os.Setenv("ONEDGE_EXAMPLE", "1")
+		exampleCounter++
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+func TestProcessStateDisabledPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "Process state changed", false)
+}
+
+func ExampleProcessStateDisabledPanicRecover() {
+	DisableChecks(CheckProcessState)
+	defer EnableChecks(CheckProcessState)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		os.Chdir(os.TempDir())
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
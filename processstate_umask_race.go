@@ -0,0 +1,53 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race,!windows
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+//====================================================================================================//
+
+// readUmask returns the process's umask.  Where possible (Linux 4.7 and later), the umask is read from
+// /proc/self/status.  Otherwise, the umask is read by setting it and then immediately restoring it.
+// Note that files created by other threads in between could be given the wrong permissions.
+func readUmask() (int, bool) {
+	if file, err := os.Open("/proc/self/status"); err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if value := strings.TrimPrefix(scanner.Text(), "Umask:"); value != scanner.Text() {
+				if umask, err := strconv.ParseInt(strings.TrimSpace(value), 8, 0); err == nil {
+					return int(umask), true
+				}
+			}
+		}
+	}
+	umask := syscall.Umask(0)
+	syscall.Umask(umask)
+	return umask, true
+}
+
+//====================================================================================================//
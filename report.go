@@ -0,0 +1,168 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// This file contains OnEdge's reporting of findings.  By default, findings are printed to stderr, with
+// each line prefixed by "=== ".  SetReporter allows findings to be handled in other ways, e.g., by the
+// onedgetest package, which converts them into test failures.  Data races are reported by Go's race
+// detector, not by OnEdge, and so they are not findings in this sense.
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+//====================================================================================================//
+
+// FindingKind is the kind of a Finding.
+type FindingKind string
+
+const (
+	// FindingNoEnclosingWrapFunc indicates that WrapRecover was called outside of any wrapped function.
+	FindingNoEnclosingWrapFunc FindingKind = "no-enclosing-wrapfunc"
+	// FindingShadowPanic indicates that the shadow thread panicked and did not recover.
+	FindingShadowPanic FindingKind = "shadow-panic"
+	// FindingDifferentPanic indicates that the shadow thread panicked with a different argument.
+	FindingDifferentPanic FindingKind = "different-panic"
+	// FindingDidNotPanic indicates that the shadow thread did not panic as it should have.
+	FindingDidNotPanic FindingKind = "did-not-panic"
+	// FindingDidNotRecover indicates that the shadow thread did not recover as it should have.
+	FindingDidNotRecover FindingKind = "did-not-recover"
+	// FindingRecoveredMultipleTimes indicates that the shadow thread recovered multiple times.
+	FindingRecoveredMultipleTimes FindingKind = "recovered-multiple-times"
+	// FindingDifferentResult indicates that the shadow thread returned a different result.
+	FindingDifferentResult FindingKind = "different-result"
+	// FindingNotIdempotent indicates that re-executing a wrapped function after a successful call
+	// panicked or returned a different result (see CheckIdempotence).
+	FindingNotIdempotent FindingKind = "not-idempotent"
+	// FindingBrokenInvariant indicates that an invariant held on entry to a wrapped function, but fails
+	// after recover.
+	FindingBrokenInvariant FindingKind = "broken-invariant"
+	// FindingArgumentChanged indicates that an argument in Options.Args changed between entry and
+	// recover.
+	FindingArgumentChanged FindingKind = "argument-changed"
+	// FindingProcessStateChanged indicates that the process state changed between entry and recover.
+	FindingProcessStateChanged FindingKind = "process-state-changed"
+	// FindingHeldLock indicates that a lock acquired after entry is still held after recover.
+	FindingHeldLock FindingKind = "held-lock"
+	// FindingExternalEffect indicates that an external state change was recorded between entry and
+	// recover.
+	FindingExternalEffect FindingKind = "external-effect"
+	// FindingLeakedFD indicates that a file descriptor opened after entry is still open after recover.
+	FindingLeakedFD FindingKind = "leaked-fd"
+	// FindingLeakedGoroutine indicates that a goroutine started after entry is still running after
+	// recover.
+	FindingLeakedGoroutine FindingKind = "leaked-goroutine"
+	// FindingPanicAfterCheckpoint indicates that a panic occurred after a call to Checkpoint, and so
+	// the shadow thread re-executed the function only up to that call (see Checkpoint).
+	FindingPanicAfterCheckpoint FindingKind = "panic-after-checkpoint"
+)
+
+//====================================================================================================//
+
+// Finding is a problem found by OnEdge.
+type Finding struct {
+	Kind FindingKind
+	// Site is the location of the enclosing most call to WrapFunc, or one of its variants.  Site is the
+	// zero Site if there is no such call, or if Go's race detector is not enabled.
+	Site Site
+	// Message describes the finding, e.g., "Shadow thread did not panic as it should have."  Message
+	// may span multiple lines.
+	Message string
+	// Stack is the main thread's stack at the enclosing most call to WrapFunc, in the style of a Go
+	// stack trace.  Stack is empty if Site is the zero Site.
+	Stack string
+	// RecoverSite is the location of the call to WrapRecover that received the panic, if any, in the
+	// enclosing most call to WrapFunc.  RecoverSite is the zero Site if there is no such call, or if
+	// Go's race detector is not enabled.
+	RecoverSite Site
+	// Checkpoint is the location of the last call to Checkpoint made by the enclosing most wrapped
+	// function before it panicked (see Checkpoint).  Checkpoint is the zero Site if there is no such
+	// call, or if Go's race detector is not enabled.
+	Checkpoint Site
+	// Test is the name of the Test, Benchmark, or Fuzz function that was running when the finding was
+	// reported, e.g., "TestTransfer".  Test is empty if no such function was running, or if Go's race
+	// detector is not enabled.
+	Test string
+}
+
+// Reporter is a function that handles findings.  A Reporter is called by the main thread, never by a
+// shadow thread.
+type Reporter func(finding Finding)
+
+// reporter is the Reporter set by SetReporter.
+var reporter Reporter = printFinding
+
+// FormatEnv is the environment variable that selects the format in which the default Reporter prints
+// findings.  If its value is "json", then each finding is printed as a single line consisting of
+// JSONPrefix followed by the finding encoded as JSON.  This is how the onedge command (see
+// "cmd/onedge") receives findings.
+const FormatEnv = "ONEDGE_FORMAT"
+
+// JSONPrefix begins each line printed by the default Reporter when FormatEnv is "json".
+const JSONPrefix = "onedge: finding: "
+
+// ShadowPrefix begins each line printed, when FormatEnv is "json", just before a shadow thread
+// re-executes a wrapped function.  The remainder of the line is a JSON object with two fields: Site,
+// the location of the call to WrapFunc; and RecoverSite, the location of the call to WrapRecover that
+// received the panic.  A data race reported by Go's race detector after such a line, and involving the
+// same call to WrapFunc, was detected during the re-execution.
+const ShadowPrefix = "onedge: shadow: "
+
+//====================================================================================================//
+
+// SetReporter sets the Reporter used to handle findings, and returns the previous one.  Passing nil
+// restores the default, which prints each finding's message to stderr, with each line prefixed by
+// "=== " (but see FormatEnv).
+func SetReporter(r Reporter) Reporter {
+	if r == nil {
+		r = printFinding
+	}
+	prev := reporter
+	reporter = r
+	return prev
+}
+
+// printFinding is the default Reporter.
+func printFinding(finding Finding) {
+	if os.Getenv(FormatEnv) == "json" {
+		data, err := json.Marshal(finding)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Fprintf(os.Stderr, "%s%s\n", JSONPrefix, data)
+		return
+	}
+	fmt.Fprint(os.Stderr, prefixLines("=== ", finding.Message))
+}
+
+//====================================================================================================//
+
+// prefixLines prefixes each line of s with prefix, and ensures that s ends with a newline.
+func prefixLines(prefix string, s string) string {
+	var builder strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		builder.WriteString(prefix + line + "\n")
+	}
+	return builder.String()
+}
+
+//====================================================================================================//
@@ -0,0 +1,35 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build !race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+)
+
+//====================================================================================================//
+
+// report passes a finding of the given kind, with a message formatted according to format, to the
+// Reporter (see "report.go").  In this version, the only findings are broken invariants.
+func report(kind FindingKind, format string, args ...interface{}) {
+	reporter(Finding{Kind: kind, Message: fmt.Sprintf(format, args...)})
+}
+
+//====================================================================================================//
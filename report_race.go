@@ -0,0 +1,126 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/trailofbits/on-edge/internal/testname"
+)
+
+//====================================================================================================//
+
+// report passes a finding of the given kind, with a message formatted according to format, to the
+// Reporter (see "report.go").  The finding is attributed to the enclosing most call to WrapFuncR, if
+// any, and is recorded in that call's site's statistics.  If that call's findings are being held (see
+// holdFindings), then the finding is passed to the Reporter later.  report must be called by the main
+// thread.
+func report(kind FindingKind, format string, args ...interface{}) {
+	finding := Finding{Kind: kind, Message: fmt.Sprintf(format, args...)}
+	if len(mainThreadStack) > 0 {
+		wrappedFunc := &mainThreadStack[len(mainThreadStack)-1]
+		finding.Site = wrappedFunc.site
+		finding.RecoverSite = wrappedFunc.recoverSite
+		if wrappedFunc.recovered && wrappedFunc.checkpoints > 0 {
+			finding.Checkpoint = wrappedFunc.checkpointSite
+		}
+		finding.Stack = formatCallers(wrappedFunc.callers)
+		finding.Test = enclosingTest(wrappedFunc.callers)
+		if wrappedFunc.holdingFindings {
+			wrappedFunc.heldFindings = append(wrappedFunc.heldFindings, finding)
+			return
+		}
+		recordFinding(wrappedFunc.site, kind)
+	} else {
+		finding.Test = enclosingTest(callers())
+	}
+	reporter(finding)
+}
+
+// holdFindings causes report to hold the findings for the enclosing most call to WrapFuncR, rather
+// than pass them to the Reporter, until releaseFindings is called.  WrapRecover holds findings while
+// the race detector's handling of synchronization is disabled, as a Reporter may synchronize (e.g., by
+// printing, or by calling t.Errorf).
+func holdFindings() {
+	mainThreadStack[len(mainThreadStack)-1].holdingFindings = true
+}
+
+// releaseFindings passes the findings held since holdFindings was called to the Reporter, in the order
+// in which they were reported, and records them in the site's statistics.
+func releaseFindings() {
+	wrappedFunc := &mainThreadStack[len(mainThreadStack)-1]
+	held := wrappedFunc.heldFindings
+	wrappedFunc.holdingFindings = false
+	wrappedFunc.heldFindings = nil
+	for _, finding := range held {
+		recordFinding(finding.Site, finding.Kind)
+		reporter(finding)
+	}
+}
+
+//====================================================================================================//
+
+// shadowStartT is printed, encoded as JSON, by announceShadow.
+type shadowStartT struct {
+	Site        Site
+	RecoverSite Site
+}
+
+// announceShadow prints a line consisting of ShadowPrefix followed by site and recoverSite encoded as
+// JSON, if FormatEnv is "json".  announceShadow is called by the main thread just before a shadow
+// thread re-executes the function wrapped at site, after a panic was received by the call to
+// WrapRecover at recoverSite.
+func announceShadow(site Site, recoverSite Site) {
+	// Disable the race detector while printing.  Printing involves synchronization (e.g., fmt's
+	// sync.Pool), and were the shadow thread to synchronize in the same way, the race detector would
+	// think that the shadow thread and the main thread were synchronized.
+	runtime.RaceDisable()
+	defer runtime.RaceEnable()
+	if os.Getenv(FormatEnv) != "json" {
+		return
+	}
+	data, err := json.Marshal(shadowStartT{Site: site, RecoverSite: recoverSite})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(os.Stderr, "%s%s\n", ShadowPrefix, data)
+}
+
+//====================================================================================================//
+
+// enclosingTest returns the name of the Test, Benchmark, or Fuzz function running on the stack
+// described by pc, if any.
+func enclosingTest(pc []uintptr) string {
+	var functions []string
+	frames := runtime.CallersFrames(pc)
+	for {
+		frame, more := frames.Next()
+		functions = append(functions, frame.Function)
+		if !more {
+			return testname.FromStack(functions)
+		}
+	}
+}
+
+//====================================================================================================//
@@ -0,0 +1,96 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"testing"
+)
+
+//====================================================================================================//
+
+func TestResultIncrementPanicRecoverFallback(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+	checkOutput(t, output, "Shadow thread returned different result: 0 != 1", true)
+}
+
+func ExampleResultIncrementPanicRecoverFallback() {
+	n := 0
+	WrapFuncR(func() (result interface{}) {
+		var seen int
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+				result = seen
+			}
+		}()
+		seen = n
+		n++
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestResultPanicRecoverFallback(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+	checkOutput(t, output, "different result", false)
+}
+
+func ExampleResultPanicRecoverFallback() {
+	WrapFuncR(func() (result interface{}) {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+				result = "fallback"
+			}
+		}()
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestResultComparatorPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+	checkOutput(t, output, "different argument", false)
+}
+
+func ExampleResultComparatorPanicRecover() {
+	SetComparator(func(x, y interface{}) bool {
+		return true
+	})
+	n := 0
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		n++
+		panic(n)
+	})
+	// Output:
+}
+
+//====================================================================================================//
@@ -0,0 +1,128 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+// This file contains OnEdge's support for taking and comparing snapshots of Go values.  A snapshot
+// maps the path of each leaf within a value (e.g., "account.owners[0].name") to a string representation
+// of that leaf.  Pointers are followed, so a snapshot is "deep".
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+//====================================================================================================//
+
+// snapshotMaxDepth bounds the number of pointers, fields, elements, etc. that add will traverse.
+const snapshotMaxDepth = 16
+
+// snapshotT maps paths to string representations of the leaves found at those paths.
+type snapshotT map[string]string
+
+//====================================================================================================//
+
+// add adds the leaves of v to the snapshot, using path as the path to v.
+func (snapshot snapshotT) add(path string, v reflect.Value) {
+	snapshot.addDepth(path, v, make(map[uintptr]bool), 0)
+}
+
+// addDepth is the recursive implementation of add.  visited contains the pointers that have been
+// followed on the way to v, and is used to avoid infinite recursion on cyclic values.
+func (snapshot snapshotT) addDepth(path string, v reflect.Value, visited map[uintptr]bool, depth int) {
+	if depth >= snapshotMaxDepth {
+		snapshot[path] = "..."
+		return
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+		snapshot[path] = "<nil>"
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			snapshot[path] = "<nil>"
+			return
+		}
+		if v.Kind() == reflect.Ptr {
+			if visited[v.Pointer()] {
+				snapshot[path] = fmt.Sprintf("<cycle %#x>", v.Pointer())
+				return
+			}
+			visited[v.Pointer()] = true
+			defer delete(visited, v.Pointer())
+		}
+		snapshot.addDepth(path, v.Elem(), visited, depth+1)
+	case reflect.Struct:
+		if v.NumField() <= 0 {
+			snapshot[path] = "{}"
+		}
+		for i := 0; i < v.NumField(); i++ {
+			snapshot.addDepth(path+"."+v.Type().Field(i).Name, v.Field(i), visited, depth+1)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			snapshot[path] = "<nil>"
+			return
+		}
+		snapshot[path+".len"] = fmt.Sprintf("%d", v.Len())
+		for i := 0; i < v.Len(); i++ {
+			snapshot.addDepth(fmt.Sprintf("%s[%d]", path, i), v.Index(i), visited, depth+1)
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			snapshot[path] = "<nil>"
+			return
+		}
+		snapshot[path+".len"] = fmt.Sprintf("%d", v.Len())
+		for _, key := range v.MapKeys() {
+			snapshot.addDepth(fmt.Sprintf("%s[%v]", path, key), v.MapIndex(key), visited, depth+1)
+		}
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		snapshot[path] = fmt.Sprintf("%#x", v.Pointer())
+	default:
+		snapshot[path] = fmt.Sprintf("%v", v)
+	}
+}
+
+//====================================================================================================//
+
+// diffSnapshots returns a sorted description of each path whose leaf differs between before and
+// after.
+func diffSnapshots(before, after snapshotT) []string {
+	var diffs []string
+	for path, beforeS := range before {
+		afterS, ok := after[path]
+		if !ok {
+			afterS = "<absent>"
+		}
+		if beforeS != afterS {
+			diffs = append(diffs, fmt.Sprintf("%s: %s -> %s", path, beforeS, afterS))
+		}
+	}
+	for path, afterS := range after {
+		if _, ok := before[path]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: <absent> -> %s", path, afterS))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+//====================================================================================================//
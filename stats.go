@@ -0,0 +1,116 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// This file contains OnEdge's runtime statistics.  The statistics are kept per call site of WrapFunc
+// (or one of its variants), and can be retrieved with Stats or published with PublishExpvar, e.g., to
+// monitor a long running soak test via /debug/vars.  Like the rest of OnEdge, the statistics are
+// gathered only when Go's race detector is enabled.
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+//====================================================================================================//
+
+// SiteStats are the statistics for the calls to WrapFunc, or one of its variants, made at one site.
+type SiteStats struct {
+	// Calls is the number of calls made by the main thread.
+	Calls uint64
+	// Panics is the number of panics received by WrapRecover.
+	Panics uint64
+	// ShadowRuns is the number of times that the shadow thread re-executed the wrapped function, and
+	// ShadowTime is the cumulative time spent doing so.
+	ShadowRuns uint64
+	ShadowTime time.Duration
+	// Reproduced is the number of panics that the shadow thread reproduced, i.e., for which the shadow
+	// thread recovered exactly once from an equivalent panic (or reached the same checkpoint).
+	Reproduced uint64
+	// Findings is the number of findings reported, by kind (see "report.go").
+	Findings map[FindingKind]uint64
+	// MaxDepth is the maximum number of calls to WrapFunc on the main thread's stack (including the
+	// call made at this site) when a call was made at this site.
+	MaxDepth int
+}
+
+// siteStats contains the statistics for each site at which a call has been made.
+var siteStats = make(map[Site]*SiteStats)
+
+// siteStatsMutex protects siteStats.  The statistics are updated by the main thread, but can be read
+// by any thread.
+var siteStatsMutex sync.Mutex
+
+// publishExpvarOnce ensures that the statistics are published at most once.
+var publishExpvarOnce sync.Once
+
+//====================================================================================================//
+
+// Stats returns a copy of the statistics for each site at which a call has been made.
+func Stats() map[Site]SiteStats {
+	siteStatsMutex.Lock()
+	defer siteStatsMutex.Unlock()
+	stats := make(map[Site]SiteStats, len(siteStats))
+	for site, s := range siteStats {
+		stats[site] = copySiteStats(s)
+	}
+	return stats
+}
+
+// PublishExpvar publishes the statistics with expvar under the name "onedge".  The published value
+// maps each site, in the form "file:line", to its statistics.  Calling PublishExpvar more than once
+// has no additional effect.
+func PublishExpvar() {
+	publishExpvarOnce.Do(func() {
+		expvar.Publish("onedge", expvar.Func(func() interface{} {
+			stats := make(map[string]SiteStats)
+			for site, s := range Stats() {
+				stats[site.String()] = s
+			}
+			return stats
+		}))
+	})
+}
+
+// copySiteStats returns a deep copy of s.
+func copySiteStats(s *SiteStats) SiteStats {
+	c := *s
+	c.Findings = make(map[FindingKind]uint64, len(s.Findings))
+	for kind, n := range s.Findings {
+		c.Findings[kind] = n
+	}
+	return c
+}
+
+//====================================================================================================//
+
+// updateStats calls update with the statistics for site, creating them if necessary.
+func updateStats(site Site, update func(s *SiteStats)) {
+	siteStatsMutex.Lock()
+	defer siteStatsMutex.Unlock()
+	s, ok := siteStats[site]
+	if !ok {
+		s = &SiteStats{Findings: make(map[FindingKind]uint64)}
+		siteStats[site] = s
+	}
+	update(s)
+}
+
+//====================================================================================================//
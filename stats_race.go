@@ -0,0 +1,77 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+// This file contains the functions that gather the statistics returned by Stats (see "stats.go").
+
+// The statistics are updated only by the main thread.  Were a shadow thread to update them, its
+// acquisition of siteStatsMutex would cause the race detector to think that the shadow thread and the
+// main thread were synchronized, and global state changes would go unreported.  For this reason,
+// findings are reported only by the main thread (see "report_race.go").
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"time"
+)
+
+//====================================================================================================//
+
+// recordCall records a call made at site.  depth is the number of calls to WrapFuncR on the main
+// thread's stack, including this one.
+func recordCall(site Site, depth int) {
+	updateStats(site, func(s *SiteStats) {
+		s.Calls++
+		if depth > s.MaxDepth {
+			s.MaxDepth = depth
+		}
+	})
+}
+
+// recordPanic records a panic received by WrapRecover for a call made at site.
+func recordPanic(site Site) {
+	updateStats(site, func(s *SiteStats) {
+		s.Panics++
+	})
+}
+
+// recordShadowRun records a re-execution, lasting d, of the function wrapped at site.
+func recordShadowRun(site Site, d time.Duration) {
+	updateStats(site, func(s *SiteStats) {
+		s.ShadowRuns++
+		s.ShadowTime += d
+	})
+}
+
+// recordReproduced records a panic, received by WrapRecover for a call made at site, that the shadow
+// thread reproduced.
+func recordReproduced(site Site) {
+	updateStats(site, func(s *SiteStats) {
+		s.Reproduced++
+	})
+}
+
+// recordFinding records a finding of the given kind for a call made at site.
+func recordFinding(site Site, kind FindingKind) {
+	updateStats(site, func(s *SiteStats) {
+		s.Findings[kind]++
+	})
+}
+
+//====================================================================================================//
@@ -0,0 +1,130 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+//====================================================================================================//
+
+// printStats prints the statistics for each site, ordered by line.  ShadowTime is printed only as
+// whether it is non-zero.
+func printStats() {
+	stats := Stats()
+	sites := make([]Site, 0, len(stats))
+	for site := range stats {
+		sites = append(sites, site)
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		return sites[i].Line < sites[j].Line
+	})
+	for _, site := range sites {
+		s := stats[site]
+		fmt.Printf(
+			"calls %d, panics %d, shadows %d (%v), reproduced %d, depth %d, findings %v\n",
+			s.Calls,
+			s.Panics,
+			s.ShadowRuns,
+			s.ShadowTime > 0,
+			s.Reproduced,
+			s.MaxDepth,
+			s.Findings,
+		)
+	}
+}
+
+//====================================================================================================//
+
+func TestStatsPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+}
+
+func ExampleStatsPanicRecover() {
+	for i := 0; i < 2; i++ {
+		WrapFunc(func() {
+			defer func() {
+				if r := WrapRecover(recover()); r != nil {
+				}
+			}()
+			panic(fmt.Errorf(""))
+		})
+	}
+	printStats()
+	// Output: calls 2, panics 2, shadows 2 (true), reproduced 2, depth 1, findings map[]
+}
+
+//====================================================================================================//
+
+func TestStatsNestedEffectPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+}
+
+func ExampleStatsNestedEffectPanicRecover() {
+	WrapFunc(func() {
+		WrapFunc(func() {
+			defer func() {
+				if r := WrapRecover(recover()); r != nil {
+				}
+			}()
+			RecordEffect("test", "effect")
+			panic(fmt.Errorf(""))
+		})
+	})
+	printStats()
+	// Output:
+	// calls 1, panics 0, shadows 0 (false), reproduced 0, depth 1, findings map[]
+	// calls 1, panics 1, shadows 1 (true), reproduced 1, depth 2, findings map[external-effect:1]
+}
+
+//====================================================================================================//
+
+func TestStatsExpvar(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+}
+
+func ExampleStatsExpvar() {
+	PublishExpvar()
+	PublishExpvar()
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+	})
+	var stats map[string]SiteStats
+	if err := json.Unmarshal([]byte(expvar.Get("onedge").String()), &stats); err != nil {
+		panic(err)
+	}
+	for _, s := range stats {
+		fmt.Println(len(stats), s.Calls, s.Panics)
+	}
+	// Output: 1 1 0
+}
+
+//====================================================================================================//
@@ -0,0 +1,100 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+// This file contains OnEdge's support for runtime/trace and runtime/pprof.  Each call to WrapFuncR in
+// the main thread creates a trace task named "onedge.WrapFunc", to which the call's site is logged
+// under the key "onedge.site".  Within the task, the following trace regions are created:
+//   onedge.main     the main thread's execution of the wrapped function
+//   onedge.recover  the handling of a recovered panic by WrapRecover (including the shadow execution)
+//   onedge.shadow   the shadow thread's re-execution of the wrapped function
+// Moreover, the main thread's execution of the wrapped function and the shadow thread are given the
+// pprof labels "onedge.site" and "onedge.role" (either "main" or "shadow").  So the cost of OnEdge can
+// be seen in both go tool trace and CPU profiles.  The labels that the caller of WrapFuncR had are
+// restored when the wrapped function returns.
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"unsafe"
+)
+
+//====================================================================================================//
+
+// wrapperNames contains the names of the exported functions that call WrapFuncROpts.  It is set by
+// init, as setting it here would create an initialization cycle.
+var wrapperNames = make(map[string]bool)
+
+func init() {
+	for _, wrapper := range []interface{}{WrapFunc, WrapFuncOpts, WrapFuncArgs, WrapFuncR} {
+		wrapperNames[runtime.FuncForPC(reflect.ValueOf(wrapper).Pointer()).Name()] = true
+	}
+}
+
+//====================================================================================================//
+
+// callSite returns the Site of the first of pc's frames that is not in one of the functions in
+// wrapperNames.  pc should be the callers of WrapFuncROpts.
+func callSite(pc []uintptr) Site {
+	frames := runtime.CallersFrames(pc)
+	for {
+		frame, more := frames.Next()
+		if !wrapperNames[frame.Function] {
+			return Site{File: frame.File, Line: frame.Line}
+		}
+		if !more {
+			return Site{}
+		}
+	}
+}
+
+// startTask creates the trace task for a call to WrapFuncR at site.  The task is a subtask of the
+// enclosing most call's task, if any.
+func startTask(site Site) (context.Context, *trace.Task) {
+	parent := context.Background()
+	if len(mainThreadStack) > 0 {
+		parent = mainThreadStack[len(mainThreadStack)-1].ctx
+	}
+	ctx, task := trace.NewTask(parent, "onedge.WrapFunc")
+	trace.Log(ctx, "onedge.site", site.String())
+	return ctx, task
+}
+
+// profileLabels returns the pprof labels for a thread executing the wrapped function called at site.
+// role is either "main" or "shadow".
+func profileLabels(site Site, role string) pprof.LabelSet {
+	return pprof.Labels("onedge.site", site.String(), "onedge.role", role)
+}
+
+// getProfLabel and setProfLabel get and set the calling goroutine's pprof labels.  pprof.Do sets the
+// goroutine's labels to those of its context argument when its function argument returns, and
+// runtime/pprof provides no other way to save and restore the labels that the goroutine had.
+//
+//go:linkname getProfLabel runtime/pprof.runtime_getProfLabel
+func getProfLabel() unsafe.Pointer
+
+//go:linkname setProfLabel runtime/pprof.runtime_setProfLabel
+func setProfLabel(labels unsafe.Pointer)
+
+//====================================================================================================//
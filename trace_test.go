@@ -0,0 +1,115 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime/pprof"
+	"runtime/trace"
+	"testing"
+)
+
+//====================================================================================================//
+
+func TestTraceSitePanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+}
+
+func ExampleTraceSitePanicRecover() {
+	if err := trace.Start(ioutil.Discard); err != nil {
+		panic(err)
+	}
+	defer trace.Stop()
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		if !InShadowThread() {
+			fmt.Println(filepath.Base(mainThreadStack[len(mainThreadStack)-1].site.File))
+		}
+		panic(fmt.Errorf(""))
+	})
+	// Output: trace_test.go
+}
+
+//====================================================================================================//
+
+func TestTraceNamesPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+}
+
+// The trace's string table contains the names of the task, the regions, and the logged key.
+func ExampleTraceNamesPanicRecover() {
+	var buf bytes.Buffer
+	if err := trace.Start(&buf); err != nil {
+		panic(err)
+	}
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		panic(fmt.Errorf(""))
+	})
+	trace.Stop()
+	for _, name := range []string{"onedge.WrapFunc", "onedge.site", "onedge.main", "onedge.recover",
+		"onedge.shadow"} {
+		fmt.Println(name, bytes.Contains(buf.Bytes(), []byte(name)))
+	}
+	// Output:
+	// onedge.WrapFunc true
+	// onedge.site true
+	// onedge.main true
+	// onedge.recover true
+	// onedge.shadow true
+}
+
+//====================================================================================================//
+
+func TestTraceLabelsNested(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 0, nil)
+}
+
+// The caller's pprof labels, and those of an enclosing call to WrapFunc, survive a call to WrapFunc.
+func ExampleTraceLabelsNested() {
+	pprof.Do(context.Background(), pprof.Labels("example", "x"), func(context.Context) {
+		labels := getProfLabel()
+		WrapFunc(func() {
+			outer := getProfLabel()
+			WrapFunc(func() {})
+			fmt.Println(getProfLabel() == outer)
+		})
+		fmt.Println(getProfLabel() == labels)
+	})
+	// Output:
+	// true
+	// true
+}
+
+//====================================================================================================//
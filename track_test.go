@@ -0,0 +1,95 @@
+//====================================================================================================//
+// Copyright 2019 Trail of Bits
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//====================================================================================================//
+
+// +build race
+
+//====================================================================================================//
+
+package onedge
+
+import (
+	"fmt"
+	"testing"
+)
+
+//====================================================================================================//
+
+func TestTrackNegateFlagPanicIfSetRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, (1<<dataRace)|(1<<didNotPanic), fmt.Errorf("exit status 1"))
+	checkOutput(t, output, "Tracked global changed between WrapFunc entry and shadow start: "+
+		"exampleFlag: false -> true", true)
+	checkOutput(t, output, "Shadow thread's WrapFuncR result: <nil>", true)
+}
+
+func ExampleTrackNegateFlagPanicIfSetRecover() {
+	Track("exampleFlag", &exampleFlag)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		exampleFlag = !exampleFlag
+		if exampleFlag {
+			panic(fmt.Errorf(""))
+		}
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestTrackSetFlagPanicRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, 1<<dataRace, fmt.Errorf("exit status 1"))
+}
+
+func ExampleTrackSetFlagPanicRecover() {
+	Track("exampleFlag", &exampleFlag)
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		exampleFlag = true
+		panic(fmt.Errorf(""))
+	})
+	// Output:
+}
+
+//====================================================================================================//
+
+func TestTrackNothingNegateFlagPanicIfSetRecover(t *testing.T) {
+	output, err := runExample(t)
+	checkExample(t, output, err, (1<<dataRace)|(1<<didNotPanic), fmt.Errorf("exit status 1"))
+	checkOutput(t, output, "No globals are tracked", true)
+}
+
+func ExampleTrackNothingNegateFlagPanicIfSetRecover() {
+	WrapFunc(func() {
+		defer func() {
+			if r := WrapRecover(recover()); r != nil {
+			}
+		}()
+		exampleFlag = !exampleFlag
+		if exampleFlag {
+			panic(fmt.Errorf(""))
+		}
+	})
+	// Output:
+}
+
+//====================================================================================================//
@@ -14,12 +14,14 @@
 // limitations under the License.
 //====================================================================================================//
 
-// This file provides access to two symbols within ThreadSanitizer, which is part of the Go runtime.
+// This file provides access to three symbols within ThreadSanitizer, which is part of the Go runtime.
 // The symbols are given "weak" implementations so that they should be overridden be the real ("strong")
 // implementations at linktime.  Moreover, the weak implementations simply call __builtin_trap() so that
 // if something were to go wrong with the linking and they were to be called at runtime, then they will
 // cause the program to abort.
 
+#include <stdint.h>
+
 struct SuppressionContext;
 
 //====================================================================================================//
@@ -46,3 +48,11 @@ int __sanitizer_SuppressionContext_Parse(struct SuppressionContext *this, const
 }
 
 //====================================================================================================//
+
+// __tsan_malloc(ThreadState*, uptr, uptr, uptr)
+void __tsan_malloc(void *thr, void *pc, uintptr_t p, uintptr_t sz) __attribute__((weak));
+void __tsan_malloc(void *thr, void *pc, uintptr_t p, uintptr_t sz) {
+  __builtin_trap();
+}
+
+//====================================================================================================//